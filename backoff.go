@@ -0,0 +1,144 @@
+package httpclient
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackoffStrategy decides the wait between in process retries.
+type BackoffStrategy string
+
+const (
+	// BackoffFullJitter waits a random duration between 0 and the exponential backoff, same as envoy. this is the default.
+	BackoffFullJitter BackoffStrategy = "full-jitter"
+	// BackoffExponential waits base * 2^(retry-1) without any jitter.
+	BackoffExponential BackoffStrategy = "exponential"
+	// BackoffDecorrelatedJitter waits a random duration between base and 3 times the previous wait.
+	BackoffDecorrelatedJitter BackoffStrategy = "decorrelated-jitter"
+	// BackoffConstant always waits the base interval.
+	BackoffConstant BackoffStrategy = "constant"
+)
+
+// BackoffFunc returns the wait before the given retry (starting from 1), prev is the previous wait.
+type BackoffFunc func(retry int, base, max, prev time.Duration) time.Duration
+
+func validateBackoffStrategy(strategy BackoffStrategy) error {
+	switch strategy {
+	case "", BackoffFullJitter, BackoffExponential, BackoffDecorrelatedJitter, BackoffConstant:
+		return nil
+	}
+	return fmt.Errorf("invalid backoff strategy %q", strategy)
+}
+
+// envoy's default backoff, 25ms base interval with a max of 10 times the base interval
+const (
+	defaultRetryBaseInterval = 25 * time.Millisecond
+	maxIntervalToBaseRatio   = 10
+)
+
+// backoffFunc returns the BackoffFunc of the policy along with the base and max intervals after applying defaults.
+func (rp EnvoyRetryPolicy) backoffFunc() (BackoffFunc, time.Duration, time.Duration) {
+	base := rp.BaseInterval
+	if base <= 0 {
+		base = defaultRetryBaseInterval
+	}
+	max := rp.MaxInterval
+	if max <= 0 {
+		max = maxIntervalToBaseRatio * base
+	}
+	if rp.CustomBackoff != nil {
+		return rp.CustomBackoff, base, max
+	}
+	switch rp.Backoff {
+	case BackoffExponential:
+		return exponentialBackoff, base, max
+	case BackoffDecorrelatedJitter:
+		return decorrelatedJitterBackoff, base, max
+	case BackoffConstant:
+		return constantBackoff, base, max
+	default:
+		return fullJitterBackoff, base, max
+	}
+}
+
+func exponentialBackoff(retry int, base, max, _ time.Duration) time.Duration {
+	if retry > 62 {
+		return max
+	}
+	wait := base << (retry - 1)
+	if wait <= 0 || wait > max {
+		return max
+	}
+	return wait
+}
+
+func fullJitterBackoff(retry int, base, max, prev time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(exponentialBackoff(retry, base, max, prev)) + 1))
+}
+
+func decorrelatedJitterBackoff(_ int, base, max, prev time.Duration) time.Duration {
+	if prev < base {
+		prev = base
+	}
+	upper := 3 * prev
+	if upper > max || upper <= 0 {
+		upper = max
+	}
+	if upper <= base {
+		return upper
+	}
+	return base + time.Duration(rand.Int63n(int64(upper-base)+1))
+}
+
+func constantBackoff(_ int, base, _, _ time.Duration) time.Duration {
+	return base
+}
+
+// rateLimitedBackoff returns the wait requested by the upstream on a 429 or 503 response through the
+// Retry-After (seconds or http date) or X-RateLimit-Reset (seconds or unix epoch) headers.
+func rateLimitedBackoff(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	if v := strings.TrimSpace(resp.Header.Get("Retry-After")); v != "" {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil && secs >= 0 {
+			return seconds(secs), true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return nonNegative(t.Sub(now)), true
+		}
+	}
+	if v := strings.TrimSpace(resp.Header.Get("X-RateLimit-Reset")); v != "" {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil && secs >= 0 {
+			// some upstreams send the seconds until the reset and some the unix time of the reset
+			if secs > unixEpochThreshold {
+				return nonNegative(time.Unix(secs, 0).Sub(now)), true
+			}
+			return seconds(secs), true
+		}
+	}
+	return 0, false
+}
+
+// seconds converts secs to a duration, saturating instead of overflowing into a negative wait
+func seconds(secs int64) time.Duration {
+	if secs > int64(math.MaxInt64/time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// values larger than this (~2001-09-09) are considered unix timestamps rather than delta seconds
+const unixEpochThreshold = 1_000_000_000
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
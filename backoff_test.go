@@ -0,0 +1,88 @@
+package httpclient
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoffStrategies(t *testing.T) {
+	base, max := 10*time.Millisecond, 100*time.Millisecond
+
+	exponential := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond, max, max}
+	for idx, expected := range exponential {
+		if got := exponentialBackoff(idx+1, base, max, 0); got != expected {
+			t.Errorf("expected %v, got %v for retry %v", expected, got, idx+1)
+		}
+	}
+	if got := constantBackoff(5, base, max, 0); got != base {
+		t.Errorf("expected %v, got %v", base, got)
+	}
+	for retry := 1; retry < 10; retry++ {
+		if got := fullJitterBackoff(retry, base, max, 0); got < 0 || got > exponentialBackoff(retry, base, max, 0) {
+			t.Errorf("full jitter backoff %v is out of range for retry %v", got, retry)
+		}
+		if got := decorrelatedJitterBackoff(retry, base, max, 30*time.Millisecond); got < base || got > 90*time.Millisecond {
+			t.Errorf("decorrelated jitter backoff %v is out of range for retry %v", got, retry)
+		}
+	}
+
+	// defaults mirror envoy
+	_, gotBase, gotMax := EnvoyRetryPolicy{}.backoffFunc()
+	if gotBase != 25*time.Millisecond || gotMax != 250*time.Millisecond {
+		t.Errorf("expected %v and %v, got %v and %v", 25*time.Millisecond, 250*time.Millisecond, gotBase, gotMax)
+	}
+}
+
+func TestRateLimitedBackoff(t *testing.T) {
+	now := time.Date(2022, 10, 1, 10, 0, 0, 0, time.UTC)
+	testcases := []struct {
+		status   int
+		header   http.Header
+		expected time.Duration
+		ok       bool
+	}{
+		{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"3"}}, expected: 3 * time.Second, ok: true},
+		{status: http.StatusServiceUnavailable, header: http.Header{"Retry-After": {now.Add(5 * time.Second).Format(http.TimeFormat)}}, expected: 5 * time.Second, ok: true},
+		{status: http.StatusTooManyRequests, header: http.Header{"X-Ratelimit-Reset": {"2"}}, expected: 2 * time.Second, ok: true},
+		{status: http.StatusTooManyRequests, header: http.Header{"X-Ratelimit-Reset": {strconv.FormatInt(now.Add(7*time.Second).Unix(), 10)}}, expected: 7 * time.Second, ok: true},
+		{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"9999999999999"}}, expected: math.MaxInt64, ok: true},
+		{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"soon"}}, ok: false},
+		{status: http.StatusInternalServerError, header: http.Header{"Retry-After": {"3"}}, ok: false},
+	}
+	for idx, testcase := range testcases {
+		got, ok := rateLimitedBackoff(&http.Response{StatusCode: testcase.status, Header: testcase.header}, now)
+		if ok != testcase.ok || got != testcase.expected {
+			t.Errorf("expected %v %v, got %v %v for test case %v", testcase.expected, testcase.ok, got, ok, idx)
+		}
+	}
+}
+
+func TestWithEnvoyRetryPolicyBackoff(t *testing.T) {
+	cfg, err := WithEnvoyRetryPolicy(EnvoyRetryPolicy{
+		BaseInterval: 50 * time.Millisecond,
+		MaxInterval:  time.Second,
+		Backoff:      BackoffDecorrelatedJitter,
+	})(StaticRequestConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// envoy has no request headers for the backoff, nothing is sent for it
+	for name := range cfg.Headers {
+		if strings.Contains(strings.ToLower(name), "backoff") {
+			t.Errorf("expected no backoff header, got %v", name)
+		}
+	}
+
+	_, err = WithEnvoyRetryPolicy(EnvoyRetryPolicy{BaseInterval: time.Second, MaxInterval: time.Millisecond})(StaticRequestConfig{})
+	if err == nil {
+		t.Errorf("expected error to be set as max interval is less than base interval")
+	}
+	_, err = WithEnvoyRetryPolicy(EnvoyRetryPolicy{Backoff: "linear"})(StaticRequestConfig{})
+	if err == nil {
+		t.Errorf("expected error to be set as backoff strategy is invalid")
+	}
+}
@@ -0,0 +1,144 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// ErrBodyNotReplayable is returned when a request body given as a plain io.Reader has to be sent again,
+// e.g. on a redirect. in process retries are skipped for such bodies.
+var ErrBodyNotReplayable = errors.New("request body is not replayable, use WithRequestBodyFunc or an io.Seeker to allow retries and redirects")
+
+// notReplayedError is the error of an attempt that the retry policy allows to retry, but whose body can't be
+// sent again. it matches ErrBodyNotReplayable along with the error of the attempt.
+type notReplayedError struct {
+	err error
+}
+
+func (e *notReplayedError) Error() string {
+	return fmt.Sprintf("%v (not retried: %v)", e.err, ErrBodyNotReplayable)
+}
+
+func (e *notReplayedError) Unwrap() error {
+	return e.err
+}
+
+func (e *notReplayedError) Is(target error) bool {
+	return target == ErrBodyNotReplayable
+}
+
+// withNotReplayed wraps err in a notReplayedError. the *url.Error of the go std client is kept outermost,
+// so that its message is still stripped by TransportError.
+func withNotReplayed(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		wrapped := *urlErr
+		wrapped.Err = &notReplayedError{err: urlErr.Err}
+		return &wrapped
+	}
+	return &notReplayedError{err: err}
+}
+
+// WithRequestBodyReader streams the body from r instead of buffering it in memory. size is the content
+// length, use -1 if it is unknown. if r is an io.Seeker the body is rewound for retries and redirects,
+// otherwise it can be sent only once. the option is meant for a single send, it can't be a default runtime
+// option of the client.
+func WithRequestBodyReader(r io.Reader, size int64) RuntimeRequestOption {
+	open, oneShot := replayable(r)
+	bodyFunc := func() (io.ReadCloser, error) {
+		body, err := open()
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(body), nil
+	}
+
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if r == nil {
+			return c, errors.New("body reader is nil")
+		}
+		c = withBodyFunc(c, bodyFunc, size)
+		c.bodyOneShot = oneShot
+		c.bodyPerSend = true
+		return c, nil
+	}
+}
+
+// replayable returns a func that returns r for every send. if r is an io.Seeker it is rewound to where it
+// was on the first send, otherwise the func fails with ErrBodyNotReplayable after the first call.
+func replayable(r io.Reader) (open func() (io.Reader, error), oneShot bool) {
+	if seeker, ok := r.(io.Seeker); ok {
+		var once sync.Once
+		var start int64
+		var startErr error
+		return func() (io.Reader, error) {
+			// the start is recorded on the first send, so that a reader that is already partially read is rewound to where it was
+			once.Do(func() { start, startErr = seeker.Seek(0, io.SeekCurrent) })
+			if startErr != nil {
+				return nil, startErr
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return r, nil
+		}, false
+	}
+
+	var mu sync.Mutex
+	consumed := false
+	return func() (io.Reader, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if consumed {
+			return nil, ErrBodyNotReplayable
+		}
+		consumed = true
+		return r, nil
+	}, true
+}
+
+// WithRequestBodyFunc streams the body returned by bodyFunc, which is called again for every retry and
+// redirect so it must return a fresh reader each time. the content length is unknown, so the body is sent
+// with chunked encoding.
+func WithRequestBodyFunc(bodyFunc func() (io.ReadCloser, error)) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if bodyFunc == nil {
+			return c, errors.New("body func is nil")
+		}
+		return withBodyFunc(c, bodyFunc, -1), nil
+	}
+}
+
+func withBodyFunc(c RuntimeRequestConfig, bodyFunc func() (io.ReadCloser, error), size int64) RuntimeRequestConfig {
+	if size < 0 {
+		size = -1
+	}
+	c.Body, c.BodyValue, c.bodyValueSet = nil, nil, false
+	c.BodyFunc = bodyFunc
+	c.BodySize = size
+	c.bodyOneShot, c.bodyPerSend = false, false
+	return c
+}
+
+// setRequestBody sets the streaming body of the request along with GetBody so that the go std client
+// can replay it on redirects.
+func setRequestBody(req *http.Request, rrc RuntimeRequestConfig) error {
+	if rrc.BodyFunc == nil {
+		return nil
+	}
+	body, err := rrc.BodyFunc()
+	if err != nil {
+		return fmt.Errorf("opening request body: %w", err)
+	}
+	if rrc.BodySize == 0 {
+		_ = body.Close()
+		body = http.NoBody
+	}
+	req.Body = body
+	req.ContentLength = rrc.BodySize
+	req.GetBody = rrc.BodyFunc
+	return nil
+}
@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRetryingTestClient(t *testing.T, server *httptest.Server) *Client {
+	t.Helper()
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{WithGoStdClient(server.Client())},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			WithEnvoyRetryPolicy(EnvoyRetryPolicy{
+				MaxRetries:    2,
+				TotalTimeout:  5 * time.Second,
+				PerTryTimeout: time.Second,
+				RetryOn:       []RetryOnCode{RetryOnGatewayError},
+				BaseInterval:  time.Millisecond,
+			}),
+			WithRetryMode(RetryModeInProcess),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return hc
+}
+
+func TestRequestBodyReaderReplayed(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "streamed" {
+			t.Errorf("expected %v, got %v", "streamed", string(body))
+		}
+		if r.ContentLength != 8 {
+			t.Errorf("expected %v, got %v", 8, r.ContentLength)
+		}
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	hc := newRetryingTestClient(t, server)
+	err := hc.Send(context.Background(), WithMethod(MethodPut), WithRequestBodyReader(strings.NewReader("streamed"), 8), WithResponseBody(io.Discard))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hits := atomic.LoadInt32(&hits); hits != 3 {
+		t.Errorf("expected %v, got %v", 3, hits)
+	}
+}
+
+func TestRequestBodyReaderNotReplayable(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/bad-request":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/reset":
+			_, _ = io.ReadAll(r.Body)
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			_ = conn.(*net.TCPConn).SetLinger(0)
+			_ = conn.Close()
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	// io.MultiReader hides the io.Seeker of the underlying reader
+	hc := newRetryingTestClient(t, server)
+	err := hc.Send(context.Background(), WithMethod(MethodPut), WithRequestBodyReader(io.MultiReader(strings.NewReader("once")), -1), WithResponseBody(io.Discard))
+	if StatusCodeOf(err) != http.StatusBadGateway {
+		t.Fatalf("expected a status error, got %v", err)
+	}
+	if hits := atomic.LoadInt32(&hits); hits != 1 {
+		t.Errorf("expected %v, got %v", 1, hits)
+	}
+	// the retry was allowed by the policy but skipped because of the body
+	if !errors.Is(err, ErrBodyNotReplayable) {
+		t.Errorf("expected %v, got %v", ErrBodyNotReplayable, err)
+	}
+
+	// a status that isn't retried anyway doesn't blame the body
+	err = hc.Send(context.Background(), WithMethod(MethodPut), WithPath("/bad-request"), WithRequestBodyReader(io.MultiReader(strings.NewReader("once")), -1), WithResponseBody(io.Discard))
+	if StatusCodeOf(err) != http.StatusBadRequest || errors.Is(err, ErrBodyNotReplayable) {
+		t.Errorf("expected a plain status error, got %v", err)
+	}
+
+	atomic.StoreInt32(&hits, 0)
+	err = hc.Send(context.Background(), WithMethod(MethodPut), WithPath("/reset"), WithRequestBodyReader(io.MultiReader(strings.NewReader("once")), -1), WithResponseBody(io.Discard))
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) || !errors.Is(err, ErrBodyNotReplayable) {
+		t.Errorf("expected a transport error matching %v, got %v", ErrBodyNotReplayable, err)
+	}
+	if hits := atomic.LoadInt32(&hits); hits != 1 {
+		t.Errorf("expected %v, got %v", 1, hits)
+	}
+
+	redirects := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/from" {
+			http.Redirect(w, r, "/to", http.StatusTemporaryRedirect)
+		}
+	}))
+	defer redirects.Close()
+
+	hc = newTestClient(t, redirects)
+	err = hc.Send(context.Background(), WithMethod(MethodPost), WithPath("/from"), WithRequestBodyReader(io.MultiReader(strings.NewReader("once")), 4), WithResponseBody(io.Discard))
+	if !errors.Is(err, ErrBodyNotReplayable) {
+		t.Errorf("expected %v, got %v", ErrBodyNotReplayable, err)
+	}
+}
+
+func TestRequestBodyFunc(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/from" {
+			http.Redirect(w, r, "/to", http.StatusTemporaryRedirect)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	hc := newTestClient(t, server)
+	buf := &bytes.Buffer{}
+	err := hc.Send(context.Background(), WithMethod(MethodPost), WithPath("/from"), WithRequestBodyFunc(func() (io.ReadCloser, error) {
+		atomic.AddInt32(&calls, 1)
+		return io.NopCloser(strings.NewReader("fresh")), nil
+	}), WithResponseBody(buf))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != "fresh" {
+		t.Errorf("expected %v, got %v", "fresh", buf.String())
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 {
+		t.Errorf("expected %v, got %v", 2, calls)
+	}
+
+	_, err = hc.SendRaw(context.Background(), WithRequestBodyFunc(func() (io.ReadCloser, error) {
+		return nil, errors.New("boom")
+	}))
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		t.Errorf("expected a transport error, got %v", err)
+	}
+}
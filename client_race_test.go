@@ -0,0 +1,113 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// these tests are meant to be run with -race, they hammer a single client from many goroutines
+func TestConcurrentSends(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"header":%q,"query":%q,"default":%q}`, r.Header.Get("X-Id"), r.URL.Query().Get("id"), r.URL.Query().Get("default"))
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions:        []ClientOption{WithGoStdClient(server.Client())},
+		StaticRequestOptions: []StaticRequestOption{WithURL(server.URL)},
+		RuntimeRequestOptions: []RuntimeRequestOption{
+			WithRuntimeHeader("X-Id", "default"),
+			WithQueryParam("default", "yes"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type echo struct {
+		Header  string `json:"header"`
+		Query   string `json:"query"`
+		Default string `json:"default"`
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				id := fmt.Sprintf("%d-%d", i, j)
+				var resp echo
+				err := hc.Send(context.Background(),
+					WithRuntimeHeader("X-Id", id),
+					WithQueryParam("id", id),
+					WithResponseJSON(&resp),
+				)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				if resp.Header != id || resp.Query != id || resp.Default != "yes" {
+					t.Errorf("expected the options of %v to be isolated, got %+v", id, resp)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// the client's defaults are untouched by the per request options
+	if v := hc.state.Load().runtimeRequestConfig.Headers.Get("X-Id"); v != "default" {
+		t.Errorf("expected %v, got %v", "default", v)
+	}
+	if v := hc.state.Load().runtimeRequestConfig.Query.Get("id"); v != "" {
+		t.Errorf("expected the default query to be untouched, got %v", v)
+	}
+}
+
+func TestConcurrentSendsAndReloads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	options := func(version string) ConfigOptions {
+		return ConfigOptions{
+			StaticRequestOptions: []StaticRequestOption{WithURL(server.URL), WithStaticHeader("X-Version", version)},
+		}
+	}
+	hc, err := NewHTTPClient(MergeConfigOptions(options("v0"), ConfigOptions{
+		ClientOptions: []ClientOption{WithGoStdClient(server.Client())},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				var resp struct{}
+				if err := hc.Send(context.Background(), WithAddRuntimeHeader("X-Version", "call"), WithResponseJSON(&resp)); err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 1; ctx.Err() == nil; i++ {
+		if err := hc.Reload(options(fmt.Sprintf("v%d", i))); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+}
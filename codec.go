@@ -0,0 +1,228 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Codec encodes request bodies and decodes response bodies of a content type. codecs are registered on the
+// client with WithCodec and picked by the Content-Type of the request body and of the response.
+type Codec interface {
+	// ContentType is the media type the codec handles, e.g. application/json
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	// NewDecoder returns a decoder that reads a single value from r without buffering it first. Decode must
+	// return io.EOF when r is empty.
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Decoder decodes a single value from a stream
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+// JSONCodec is the encoding/json codec, it is used for json unless another one is registered for application/json.
+var JSONCodec Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return jsonDecoder{dec: json.NewDecoder(r)}
+}
+
+type jsonDecoder struct {
+	dec *json.Decoder
+}
+
+func (d jsonDecoder) Decode(v interface{}) error {
+	if err := d.dec.Decode(v); err != nil {
+		return err
+	}
+	// same as json.Unmarshal, anything but whitespace after the value is invalid
+	if _, err := d.dec.Token(); err != io.EOF {
+		return errors.New("invalid data after the top-level json value")
+	}
+	return nil
+}
+
+// builtinCodecs are used when no codec is registered for their content type
+var builtinCodecs = []Codec{JSONCodec, XMLCodec, ProtoCodec}
+
+// mediaTypeAliases are the media types that are handled by the codec of another one
+var mediaTypeAliases = map[string]string{
+	"text/json": "application/json",
+	"text/xml":  "application/xml",
+}
+
+// WithCodec registers the codec on the client, it replaces the codec registered earlier for the same content type.
+// the Accept header of requests with a negotiated response (see WithResponse) lists the registered codecs in the
+// order they were registered, the first one is also used to decode responses without a Content-Type.
+func WithCodec(codec Codec) StaticRequestOption {
+	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
+		if codec == nil {
+			return c, errors.New("codec is nil")
+		}
+		contentType := mediaType(codec.ContentType())
+		if contentType == "" {
+			return c, fmt.Errorf("invalid codec content type %q", codec.ContentType())
+		}
+		codecs := make([]Codec, 0, len(c.Codecs)+1)
+		for _, registered := range c.Codecs {
+			if mediaType(registered.ContentType()) != contentType {
+				codecs = append(codecs, registered)
+			}
+		}
+		c.Codecs = append(codecs, codec)
+		return c, nil
+	}
+}
+
+// WithResponse decodes the response body into response with the codec picked by the Content-Type of the
+// response, the Accept header is set to the content types of the registered codecs unless given.
+func WithResponse(response interface{}) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		c.Response = response
+		return c, nil
+	}
+}
+
+// withRequestBody sets the body to be encoded with the codec of contentType when the request is sent
+func withRequestBody(body interface{}, contentType string) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		c.Body, c.BodyFunc, c.bodyOneShot, c.bodyPerSend = nil, nil, false, false
+		c.BodyValue, c.bodyValueSet = body, true
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
+		c.Headers.Set("Content-Type", contentType)
+		return c, nil
+	}
+}
+
+// encodeBody encodes the BodyValue with the codec of the request Content-Type
+func (st *clientState) encodeBody(rrc RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+	if rrc.BodyValue == nil && !rrc.bodyValueSet {
+		return rrc, nil
+	}
+	contentType := rrc.Headers.Get("Content-Type")
+	codec := codecFor(st.staticRequestConfig.Codecs, contentType)
+	if codec == nil {
+		return rrc, fmt.Errorf("no codec registered for the request content type %q", contentType)
+	}
+	body, err := codec.Marshal(rrc.BodyValue)
+	if err != nil {
+		return rrc, fmt.Errorf("encoding request body as %s: %w", contentType, err)
+	}
+	rrc.Body = body
+	return rrc, nil
+}
+
+// codecFor returns the codec for the content type, the registered codecs take precedence over the builtin ones.
+// structured syntax suffixes fall back to their base type, e.g. application/problem+json is decoded as json.
+func codecFor(codecs []Codec, contentType string) Codec {
+	contentType = mediaType(contentType)
+	if contentType == "" {
+		return nil
+	}
+	candidates := []string{contentType}
+	if idx := strings.LastIndexByte(contentType, '+'); idx >= 0 {
+		candidates = append(candidates, "application/"+contentType[idx+1:])
+	}
+	if alias, ok := mediaTypeAliases[contentType]; ok {
+		candidates = append(candidates, alias)
+	}
+	for _, candidate := range candidates {
+		for _, registered := range [][]Codec{codecs, builtinCodecs} {
+			for _, codec := range registered {
+				if mediaType(codec.ContentType()) == candidate {
+					return codec
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// negotiatedCodecs are the codecs a negotiated response can be decoded with, in the order of preference
+func negotiatedCodecs(codecs []Codec) []Codec {
+	if len(codecs) == 0 {
+		return builtinCodecs[:1]
+	}
+	return codecs
+}
+
+// responseTarget returns the destination that a codec decodes the response into, along with the content type
+// of the codec. the content type is empty for a negotiated response and for the destinations without a codec.
+func responseTarget(rrc RuntimeRequestConfig) (interface{}, string) {
+	switch {
+	case rrc.Response != nil:
+		return rrc.Response, ""
+	case rrc.ResponseJSON != nil:
+		return rrc.ResponseJSON, JSONCodec.ContentType()
+	case rrc.ResponseXML != nil:
+		return rrc.ResponseXML, XMLCodec.ContentType()
+	case rrc.ResponseProto != nil:
+		return rrc.ResponseProto, ProtoCodec.ContentType()
+	}
+	return nil, ""
+}
+
+// responseCodec returns the codec to decode the response body into the response destination
+func responseCodec(static StaticRequestConfig, rrc RuntimeRequestConfig, resp *http.Response) (Codec, error) {
+	if _, contentType := responseTarget(rrc); contentType != "" {
+		return codecFor(static.Codecs, contentType), nil
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return negotiatedCodecs(static.Codecs)[0], nil
+	}
+	codec := codecFor(static.Codecs, contentType)
+	if codec == nil {
+		return nil, fmt.Errorf("no codec registered for the response content type %q", contentType)
+	}
+	return codec, nil
+}
+
+// setAccept sets the Accept header from the response destination, unless the request already has one
+func setAccept(static StaticRequestConfig, rrc RuntimeRequestConfig) {
+	if rrc.Headers.Get("Accept") != "" {
+		return
+	}
+	target, contentType := responseTarget(rrc)
+	if contentType != "" {
+		rrc.Headers.Set("Accept", codecFor(static.Codecs, contentType).ContentType())
+	} else if target != nil {
+		codecs := negotiatedCodecs(static.Codecs)
+		contentTypes := make([]string, len(codecs))
+		for i, codec := range codecs {
+			contentTypes[i] = codec.ContentType()
+		}
+		rrc.Headers.Set("Accept", strings.Join(contentTypes, ", "))
+	}
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mt
+}
@@ -0,0 +1,160 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// lineCodec encodes a []string as newline separated lines
+type lineCodec struct{}
+
+func (lineCodec) ContentType() string { return "text/x-lines" }
+
+func (lineCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strings.Join(v.([]string), "\n")), nil
+}
+
+func (lineCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]string) = strings.Split(string(data), "\n")
+	return nil
+}
+
+func (c lineCodec) NewDecoder(r io.Reader) Decoder {
+	return lineDecoder{r: bufio.NewScanner(r)}
+}
+
+type lineDecoder struct {
+	r *bufio.Scanner
+}
+
+func (d lineDecoder) Decode(v interface{}) error {
+	lines := v.(*[]string)
+	for d.r.Scan() {
+		*lines = append(*lines, d.r.Text())
+	}
+	if len(*lines) == 0 {
+		return io.EOF
+	}
+	return d.r.Err()
+}
+
+// upperJSONCodec is a json codec that tells it was used by upper casing the encoded body
+type upperJSONCodec struct {
+	jsonCodec
+}
+
+func (upperJSONCodec) ContentType() string { return "application/json; charset=utf-8" }
+
+func (c upperJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := c.jsonCodec.Marshal(v)
+	return []byte(strings.ToUpper(string(b))), err
+}
+
+func TestCodecs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept", r.Header.Get("Accept"))
+		switch r.URL.Path {
+		case "/echo":
+			w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+			_, _ = io.Copy(w, r.Body)
+		case "/lines":
+			w.Header().Set("Content-Type", "text/x-lines")
+			_, _ = w.Write([]byte("a\nb"))
+		case "/problem":
+			w.Header().Set("Content-Type", "application/problem+json")
+			_, _ = w.Write([]byte(`["c"]`))
+		case "/unknown":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html></html>"))
+		}
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{WithGoStdClient(server.Client())},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			WithCodec(JSONCodec),
+			WithCodec(lineCodec{}),
+			// replaces the json codec registered first
+			WithCodec(upperJSONCodec{}),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		opts     []RuntimeRequestOption
+		expected []string
+		accept   string
+		err      bool
+	}{
+		{
+			opts:     []RuntimeRequestOption{WithPath("/echo"), WithRequestJSON([]string{"x"})},
+			expected: []string{"X"},
+			accept:   "text/x-lines, application/json; charset=utf-8",
+		},
+		{opts: []RuntimeRequestOption{WithPath("/lines")}, expected: []string{"a", "b"}, accept: "text/x-lines, application/json; charset=utf-8"},
+		{opts: []RuntimeRequestOption{WithPath("/problem")}, expected: []string{"c"}, accept: "text/x-lines, application/json; charset=utf-8"},
+		{opts: []RuntimeRequestOption{WithPath("/lines"), WithRuntimeHeader("Accept", "text/x-lines")}, expected: []string{"a", "b"}, accept: "text/x-lines"},
+		{opts: []RuntimeRequestOption{WithPath("/unknown")}, err: true},
+	}
+	for idx, testCase := range testCases {
+		var resp []string
+		var raw *http.Response
+		err := hc.Send(context.Background(), append(testCase.opts, WithResponse(&resp), WithRequestMiddlewares(func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				var err error
+				raw, err = next(req)
+				return raw, err
+			}
+		}))...)
+		if testCase.err {
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Errorf("test case %v: expected a decode error, got %v", idx, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test case %v: unexpected error: %v", idx, err)
+			continue
+		}
+		if strings.Join(resp, ",") != strings.Join(testCase.expected, ",") {
+			t.Errorf("test case %v: expected %v, got %v", idx, testCase.expected, resp)
+		}
+		if accept := raw.Header.Get("X-Accept"); accept != testCase.accept {
+			t.Errorf("test case %v: expected %v, got %v", idx, testCase.accept, accept)
+		}
+	}
+}
+
+func TestRequestJSONNil(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "null" {
+			t.Errorf("expected %v, got %q", "null", string(body))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	hc := newTestClient(t, server)
+
+	err := hc.Send(context.Background(), WithMethod(MethodPost), WithRequestJSON(nil), WithResponseBody(io.Discard))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	type user struct{}
+	var nilUser *user
+	if _, err := PostJSON[*user, user](context.Background(), hc, nilUser); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Compressor implements a content coding, e.g. gzip. other codings such as zstd or br can be plugged in with
+// RegisterCompressor.
+type Compressor interface {
+	// Encoding is the content coding token used in the Content-Encoding and Accept-Encoding headers
+	Encoding() string
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	Gzip    Compressor = gzipCompressor{}
+	Deflate Compressor = deflateCompressor{}
+)
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+func (gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// deflateCompressor is the http deflate coding, which is the zlib format and not raw deflate
+type deflateCompressor struct{}
+
+func (deflateCompressor) Encoding() string {
+	return "deflate"
+}
+
+func (deflateCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func (deflateCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+var compressors = struct {
+	sync.RWMutex
+	// list is in the order of registration, it is the order of the Accept-Encoding header
+	list []Compressor
+}{list: []Compressor{Gzip, Deflate}}
+
+// RegisterCompressor registers the compressor for all the clients, it replaces the compressor registered
+// earlier for the same encoding. responses in a registered encoding are decompressed transparently.
+func RegisterCompressor(c Compressor) {
+	compressors.Lock()
+	defer compressors.Unlock()
+	list := make([]Compressor, 0, len(compressors.list)+1)
+	for _, registered := range compressors.list {
+		if !strings.EqualFold(registered.Encoding(), c.Encoding()) {
+			list = append(list, registered)
+		}
+	}
+	compressors.list = append(list, c)
+}
+
+func compressorFor(encoding string) Compressor {
+	compressors.RLock()
+	defer compressors.RUnlock()
+	for _, c := range compressors.list {
+		if strings.EqualFold(c.Encoding(), encoding) {
+			return c
+		}
+	}
+	return nil
+}
+
+func acceptEncoding() string {
+	compressors.RLock()
+	defer compressors.RUnlock()
+	encodings := make([]string, len(compressors.list))
+	for i, c := range compressors.list {
+		encodings[i] = c.Encoding()
+	}
+	return strings.Join(encodings, ", ")
+}
+
+// WithRequestCompression compresses the request body with c and sets the Content-Encoding header. bodies smaller
+// than the size set with WithRequestCompressionMinSize are sent as is. only buffered bodies can be compressed,
+// i.e. not the ones given with WithRequestBodyReader, WithRequestBodyFunc or WithMultipart.
+func WithRequestCompression(c Compressor) RuntimeRequestOption {
+	return func(rc RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if c == nil {
+			return rc, errors.New("compressor is nil")
+		}
+		rc.Compression = c
+		return rc, nil
+	}
+}
+
+// WithRequestCompressionMinSize sets the size in bytes below which request bodies are not compressed.
+func WithRequestCompressionMinSize(n int) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if n < 0 {
+			return c, errors.New("compression min size cannot be negative")
+		}
+		c.CompressionMinSize = n
+		return c, nil
+	}
+}
+
+// compressBody compresses the encoded body, it runs after encodeBody
+func compressBody(rrc RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+	if rrc.Compression == nil {
+		return rrc, nil
+	}
+	if rrc.BodyFunc != nil {
+		return rrc, errors.New("streamed request bodies cannot be compressed")
+	}
+	if len(rrc.Body) == 0 || len(rrc.Body) < rrc.CompressionMinSize {
+		return rrc, nil
+	}
+	var buf bytes.Buffer
+	w, err := rrc.Compression.NewWriter(&buf)
+	if err != nil {
+		return rrc, fmt.Errorf("compressing request body with %s: %w", rrc.Compression.Encoding(), err)
+	}
+	if _, err := w.Write(rrc.Body); err != nil {
+		return rrc, fmt.Errorf("compressing request body with %s: %w", rrc.Compression.Encoding(), err)
+	}
+	if err := w.Close(); err != nil {
+		return rrc, fmt.Errorf("compressing request body with %s: %w", rrc.Compression.Encoding(), err)
+	}
+	rrc.Body = buf.Bytes()
+	rrc.Headers.Set("Content-Encoding", rrc.Compression.Encoding())
+	return rrc, nil
+}
+
+// decompressResponse decompresses the body of a response in a registered encoding. as with the go std
+// transport, the Content-Encoding and Content-Length headers are removed as they no longer describe the body.
+func decompressResponse(resp *http.Response) {
+	encoding := strings.TrimSpace(resp.Header.Get("Content-Encoding"))
+	if encoding == "" {
+		return
+	}
+	c := compressorFor(encoding)
+	if c == nil {
+		return
+	}
+	resp.Body = &decompressingBody{body: resp.Body, compressor: c}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decompressingBody creates the reader on the first read, as creating it already reads from the body
+type decompressingBody struct {
+	body       io.ReadCloser
+	compressor Compressor
+	r          io.ReadCloser
+	err        error
+}
+
+func (b *decompressingBody) Read(p []byte) (int, error) {
+	if b.r == nil && b.err == nil {
+		b.r, b.err = b.compressor.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.r.Read(p)
+}
+
+func (b *decompressingBody) Close() error {
+	if b.r != nil {
+		_ = b.r.Close()
+	}
+	return b.body.Close()
+}
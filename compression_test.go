@@ -0,0 +1,137 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestCompression(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			body = zr
+		}
+		w.Header().Set("X-Content-Encoding", r.Header.Get("Content-Encoding"))
+		_, _ = io.Copy(w, body)
+	}))
+	defer server.Close()
+
+	hc := newTestClient(t, server, WithRequestCompression(Gzip), WithRequestCompressionMinSize(10))
+	testCases := []struct {
+		body     string
+		encoding string
+	}{
+		{body: strings.Repeat("a", 100), encoding: "gzip"},
+		{body: "short", encoding: ""},
+	}
+	for idx, testCase := range testCases {
+		resp, err := hc.SendRaw(context.Background(), WithMethod(MethodPost), WithFormBody(map[string][]string{"q": {testCase.body}}))
+		if err != nil {
+			t.Errorf("test case %v: unexpected error: %v", idx, err)
+			continue
+		}
+		body, _ := resp.String()
+		resp.Close()
+		if body != "q="+testCase.body {
+			t.Errorf("test case %v: expected %v, got %v", idx, "q="+testCase.body, body)
+		}
+		if encoding := resp.Header.Get("X-Content-Encoding"); encoding != testCase.encoding {
+			t.Errorf("test case %v: expected %v, got %v", idx, testCase.encoding, encoding)
+		}
+	}
+}
+
+func TestResponseDecompression(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		if r.URL.Path == "/range" {
+			return
+		}
+		var buf bytes.Buffer
+		var zw io.WriteCloser
+		switch r.URL.Path {
+		case "/gzip":
+			zw = gzip.NewWriter(&buf)
+		case "/deflate":
+			zw = zlib.NewWriter(&buf)
+		}
+		_, _ = zw.Write([]byte("decompressed"))
+		_ = zw.Close()
+		w.Header().Set("Content-Encoding", strings.TrimPrefix(r.URL.Path, "/"))
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	// the transport must not decompress on its own
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.DisableCompression = true
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions:        []ClientOption{WithTransport(transport)},
+		StaticRequestOptions: []StaticRequestOption{WithURL(server.URL)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for idx, path := range []string{"/gzip", "/deflate"} {
+		var raw *http.Response
+		buf := &bytes.Buffer{}
+		err := hc.Send(context.Background(), WithPath(path), WithResponseBody(buf), WithRequestMiddlewares(func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				var err error
+				raw, err = next(req)
+				return raw, err
+			}
+		}))
+		if err != nil {
+			t.Errorf("test case %v: unexpected error: %v", idx, err)
+			continue
+		}
+		if buf.String() != "decompressed" {
+			t.Errorf("test case %v: expected %v, got %v", idx, "decompressed", buf.String())
+		}
+		if v := raw.Header.Get("X-Accept-Encoding"); v != "gzip, deflate" {
+			t.Errorf("test case %v: expected %v, got %v", idx, "gzip, deflate", v)
+		}
+		if v := raw.Header.Get("Content-Encoding"); v != "" {
+			t.Errorf("test case %v: expected no content encoding, got %v", idx, v)
+		}
+	}
+
+	// the body is left compressed when the caller asks for an encoding
+	resp, err := hc.SendRaw(context.Background(), WithPath("/gzip"), WithRuntimeHeader("Accept-Encoding", "gzip"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Close()
+	zr, err := gzip.NewReader(resp.Body())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+	if string(body) != "decompressed" {
+		t.Errorf("expected %v, got %v", "decompressed", string(body))
+	}
+
+	// range requests don't ask for an encoding
+	rangeResp, err := hc.SendRaw(context.Background(), WithPath("/range"), WithRuntimeHeader("Range", "bytes=0-3"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rangeResp.Close()
+	if v := rangeResp.Header.Get("X-Accept-Encoding"); v != "" {
+		t.Errorf("expected no accept encoding, got %v", v)
+	}
+}
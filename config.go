@@ -1,6 +1,7 @@
 package httpclient
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
@@ -11,33 +12,98 @@ type ClientConfig struct {
 	Transport     *http.Transport
 	Client        *http.Client
 	ClientTimeout time.Duration
+	Middlewares   []Middleware
 }
 
 type StaticRequestConfig struct {
-	Scheme   string
-	User     string
-	Password string
-	Host     string
-	Headers  http.Header
+	Scheme      string
+	User        string
+	Password    string
+	Host        string
+	Headers     http.Header
+	RetryPolicy *EnvoyRetryPolicy
+	RetryMode   RetryMode
+	// ExpectedStatus applies to every request unless the request sets its own, defaults to 2xx when empty
+	ExpectedStatus []StatusRange
+	// MaxResponseBytes and ReadIdleTimeout apply to every request unless the request sets its own, zero means no limit
+	MaxResponseBytes int64
+	ReadIdleTimeout  time.Duration
+	// Codecs are the codecs registered with WithCodec, see codecFor for the builtin ones
+	Codecs []Codec
 }
 
 func (c StaticRequestConfig) Clone() StaticRequestConfig {
-	return StaticRequestConfig{
-		Scheme:  c.Scheme,
-		User:    c.User,
-		Host:    c.Host,
-		Headers: c.Headers.Clone(),
+	clone := c
+	clone.Headers = c.Headers.Clone()
+	clone.ExpectedStatus = append([]StatusRange(nil), c.ExpectedStatus...)
+	clone.Codecs = append([]Codec(nil), c.Codecs...)
+	if c.RetryPolicy != nil {
+		retryPolicy := c.RetryPolicy.Clone()
+		clone.RetryPolicy = &retryPolicy
 	}
+	return clone
 }
 
 type RuntimeRequestConfig struct {
-	Headers      http.Header
-	Body         []byte
-	ResponseJSON interface{}
-	ResponseBody io.Writer
-	Method       HttpMethod
-	Path         string
-	Query        url.Values
+	Headers http.Header
+	Body    []byte
+	// BodyFunc streams the body instead of Body, it is called for every attempt and redirect.
+	// BodySize is the content length of the streamed body, -1 if unknown.
+	BodyFunc func() (io.ReadCloser, error)
+	BodySize int64
+	// BodyValue is encoded into Body with the codec of the request Content-Type when the request is sent
+	BodyValue     interface{}
+	ResponseJSON  interface{}
+	ResponseXML   interface{}
+	ResponseProto ProtoUnmarshaler
+	ResponseBody  io.Writer
+	// Response is decoded with the codec picked by the response Content-Type
+	Response interface{}
+	// ResponseHandler is given the response when its status is expected, the body is closed after it returns
+	ResponseHandler func(*http.Response) error
+	Method          HttpMethod
+	Path            string
+	Query           url.Values
+	Middlewares     []Middleware
+	// ExpectedStatus overrides the ExpectedStatus of the static config when set
+	ExpectedStatus    []StatusRange
+	ErrorResponseJSON []ErrorResponseTarget
+	// MaxResponseBytes and ReadIdleTimeout override the ones of the static config when set
+	MaxResponseBytes int64
+	ReadIdleTimeout  time.Duration
+
+	// TimingsHook is called with the timings of the request, see WithTimingsHook
+	TimingsHook func(Timings)
+	// RouteTemplate is the route label of the request metrics, see WithRouteTemplate
+	RouteTemplate string
+	// Compression compresses Body when it is at least CompressionMinSize bytes
+	Compression        Compressor
+	CompressionMinSize int
+
+	// bodyOneShot is set when BodyFunc can't be called more than once, such bodies are not retried
+	bodyOneShot bool
+	// bodyPerSend is set when BodyFunc belongs to a single send, e.g. it rewinds a reader given by the caller,
+	// so it can't be shared by the requests of the client
+	bodyPerSend bool
+	// bodyValueSet is set when BodyValue was given, so that a nil value is encoded too, e.g. as json null
+	bodyValueSet bool
+}
+
+// Clone deep copies the config so that options applied on the clone don't leak into the original.
+// Body is shared as options only ever replace it.
+func (c RuntimeRequestConfig) Clone() RuntimeRequestConfig {
+	clone := c
+	clone.Headers = c.Headers.Clone()
+	if c.Query != nil {
+		clone.Query = make(url.Values, len(c.Query))
+		for k, vs := range c.Query {
+			clone.Query[k] = append([]string(nil), vs...)
+		}
+	}
+	clone.Middlewares = append([]Middleware(nil), c.Middlewares...)
+	clone.ExpectedStatus = append([]StatusRange(nil), c.ExpectedStatus...)
+	clone.ErrorResponseJSON = append([]ErrorResponseTarget(nil), c.ErrorResponseJSON...)
+	return clone
 }
 
 type Config struct {
@@ -64,31 +130,33 @@ func NewConfig(co ConfigOptions) (Config, error) {
 	}
 
 	// apply client options
-	for _, opt := range co.ClientOptions {
+	for idx, opt := range co.ClientOptions {
 		var err error
 		c.ClientConfig, err = opt(c.ClientConfig)
 		if err != nil {
-			return c, err // todo: error wrapping
+			return c, &ConfigError{Kind: ConfigKindClient, Index: idx, Err: err}
 		}
 	}
 	// apply static request options
-	for _, opt := range co.StaticRequestOptions {
+	for idx, opt := range co.StaticRequestOptions {
 		var err error
 		c.StaticRequestConfig, err = opt(c.StaticRequestConfig)
 		if err != nil {
-			return c, err // todo: error wrapping
+			return c, &ConfigError{Kind: ConfigKindStaticRequest, Index: idx, Err: err}
 		}
 	}
 	// apply runtime request options
-	for _, opt := range co.RuntimeRequestOptions {
+	for idx, opt := range co.RuntimeRequestOptions {
 		var err error
 		c.RuntimeRequestConfig, err = opt(c.RuntimeRequestConfig)
 		if err != nil {
-			return c, err // todo: error wrapping
+			return c, &ConfigError{Kind: ConfigKindRuntimeRequest, Index: idx, Err: err}
 		}
 	}
+	if c.RuntimeRequestConfig.bodyPerSend {
+		// the default runtime config is shared by all the requests of the client
+		return c, &ConfigError{Kind: ConfigKindRuntimeRequest, Index: -1, Err: errors.New("the bodies of WithMultipart and WithRequestBodyReader belong to a single send, they can't be default runtime options")}
+	}
 
 	return c, nil
 }
-
-// generate config from yaml node or json node. this will be helpful in standardizing config across services config.yaml file
@@ -0,0 +1,322 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigFromEnv reads the client config from environment variables named <PREFIX>_HTTP_<KEY>, e.g. with the
+// prefix "payments": PAYMENTS_HTTP_HOST=payments.internal:8443, PAYMENTS_HTTP_TIMEOUT=750ms, PAYMENTS_HTTP_MAX_RETRIES=2.
+// unset variables produce no options, so the result is meant to be layered over the defaults with MergeConfigOptions.
+// the retry variables overlay the fields of the retry policy set by the earlier layers instead of replacing it.
+// all invalid variables are reported together in an *EnvConfigError.
+//
+// supported keys: SCHEME, HOST, HOST_HEADER, TIMEOUT, MAX_IDLE_CONNS, MAX_IDLE_CONNS_PER_HOST, MAX_CONNS_PER_HOST,
+// IDLE_CONN_TIMEOUT, DIAL_TIMEOUT, TLS_HANDSHAKE_TIMEOUT, TLS_CA_FILE, TLS_CERT_FILE, TLS_KEY_FILE, TLS_SERVER_NAME,
+// TLS_INSECURE_SKIP_VERIFY, RETRY_MODE, MAX_RETRIES, TOTAL_TIMEOUT, PER_TRY_TIMEOUT, RETRY_ON, RETRIABLE_STATUS_CODES,
+// RETRIABLE_HEADERS, RETRY_BACKOFF, RETRY_BASE_INTERVAL, RETRY_MAX_INTERVAL. lists are comma separated.
+func ConfigFromEnv(prefix string) (ConfigOptions, error) {
+	return configFromEnv(prefix, os.LookupEnv)
+}
+
+// MergeConfigOptions concatenates the layers in order, since options are applied in order the later layers
+// take precedence. the usual order is: defaults, file config, env, options given in code.
+func MergeConfigOptions(layers ...ConfigOptions) ConfigOptions {
+	var merged ConfigOptions
+	for _, layer := range layers {
+		merged.ClientOptions = append(merged.ClientOptions, layer.ClientOptions...)
+		merged.StaticRequestOptions = append(merged.StaticRequestOptions, layer.StaticRequestOptions...)
+		merged.RuntimeRequestOptions = append(merged.RuntimeRequestOptions, layer.RuntimeRequestOptions...)
+	}
+	return merged
+}
+
+// EnvConfigError holds the errors of all the invalid environment variables
+type EnvConfigError struct {
+	Errors []error
+}
+
+func (e *EnvConfigError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "invalid environment variables: " + strings.Join(msgs, "; ")
+}
+
+// Is and As match the individual errors, multi error unwrapping isn't supported by the errors package of go 1.19
+func (e *EnvConfigError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *EnvConfigError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type envReader struct {
+	prefix string
+	lookup func(string) (string, bool)
+	errs   []error
+}
+
+func (r *envReader) get(key string) (string, string, bool) {
+	name := r.prefix + key
+	v, ok := r.lookup(name)
+	return name, strings.TrimSpace(v), ok
+}
+
+func (r *envReader) fail(name string, err error) {
+	r.errs = append(r.errs, fmt.Errorf("%s: %w", name, err))
+}
+
+func (r *envReader) string(key string, validate func(string) error, apply func(string)) {
+	name, v, ok := r.get(key)
+	if !ok {
+		return
+	}
+	if validate != nil {
+		if err := validate(v); err != nil {
+			r.fail(name, err)
+			return
+		}
+	}
+	apply(v)
+}
+
+func (r *envReader) duration(key string, apply func(time.Duration)) {
+	name, v, ok := r.get(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		r.fail(name, err)
+		return
+	}
+	if d < 0 {
+		r.fail(name, errors.New("duration cannot be negative"))
+		return
+	}
+	apply(d)
+}
+
+func (r *envReader) uint(key string, bitSize int, apply func(uint64)) {
+	name, v, ok := r.get(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.ParseUint(v, 10, bitSize)
+	if err != nil {
+		r.fail(name, err)
+		return
+	}
+	apply(n)
+}
+
+func (r *envReader) bool(key string, apply func(bool)) {
+	name, v, ok := r.get(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		r.fail(name, err)
+		return
+	}
+	apply(b)
+}
+
+func (r *envReader) list(key string, apply func([]string)) {
+	name, v, ok := r.get(key)
+	if !ok {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		r.fail(name, errors.New("list is empty"))
+		return
+	}
+	apply(items)
+}
+
+func configFromEnv(prefix string, lookup func(string) (string, bool)) (ConfigOptions, error) {
+	r := &envReader{prefix: envPrefix(prefix), lookup: lookup}
+	var co ConfigOptions
+
+	// client options
+	r.duration("TIMEOUT", func(d time.Duration) {
+		co.ClientOptions = append(co.ClientOptions, WithClientTimeout(d))
+	})
+	var transportOpts []ClientOption
+	var pool struct {
+		maxIdleConns, maxIdleConnsPerHost, maxConnsPerHost int
+		idleConnTimeout                                    time.Duration
+		set                                                bool
+	}
+	r.uint("MAX_IDLE_CONNS", 31, func(n uint64) { pool.maxIdleConns, pool.set = int(n), true })
+	r.uint("MAX_IDLE_CONNS_PER_HOST", 31, func(n uint64) { pool.maxIdleConnsPerHost, pool.set = int(n), true })
+	r.uint("MAX_CONNS_PER_HOST", 31, func(n uint64) { pool.maxConnsPerHost, pool.set = int(n), true })
+	r.duration("IDLE_CONN_TIMEOUT", func(d time.Duration) { pool.idleConnTimeout, pool.set = d, true })
+	if pool.set {
+		transportOpts = append(transportOpts, WithConnectionPool(pool.maxIdleConns, pool.maxIdleConnsPerHost, pool.maxConnsPerHost, pool.idleConnTimeout))
+	}
+	r.duration("DIAL_TIMEOUT", func(d time.Duration) {
+		transportOpts = append(transportOpts, WithDialTimeout(d))
+	})
+	r.duration("TLS_HANDSHAKE_TIMEOUT", func(d time.Duration) {
+		transportOpts = append(transportOpts, WithTLSHandshakeTimeout(d))
+	})
+	r.string("TLS_CA_FILE", nil, func(v string) {
+		transportOpts = append(transportOpts, WithRootCAFile(v))
+	})
+	certName, certFile, certOk := r.get("TLS_CERT_FILE")
+	keyName, keyFile, keyOk := r.get("TLS_KEY_FILE")
+	if certOk && keyOk {
+		transportOpts = append(transportOpts, WithClientCertificateFiles(certFile, keyFile))
+	} else if certOk {
+		r.fail(certName, fmt.Errorf("%s is not set", keyName))
+	} else if keyOk {
+		r.fail(keyName, fmt.Errorf("%s is not set", certName))
+	}
+	r.string("TLS_SERVER_NAME", nil, func(v string) {
+		transportOpts = append(transportOpts, WithTLSServerName(v))
+	})
+	r.bool("TLS_INSECURE_SKIP_VERIFY", func(b bool) {
+		transportOpts = append(transportOpts, WithInsecureSkipVerify(b))
+	})
+	if len(transportOpts) > 0 {
+		co.ClientOptions = append(co.ClientOptions, ensureTransport())
+		co.ClientOptions = append(co.ClientOptions, transportOpts...)
+	}
+
+	// static request options
+	r.string("SCHEME", validateScheme, func(v string) {
+		co.StaticRequestOptions = append(co.StaticRequestOptions, WithScheme(v))
+	})
+	r.string("HOST", validateHostPort, func(v string) {
+		co.StaticRequestOptions = append(co.StaticRequestOptions, WithHostPort(v))
+	})
+	r.string("HOST_HEADER", validateHostHeader, func(v string) {
+		co.StaticRequestOptions = append(co.StaticRequestOptions, WithHostHeader(v))
+	})
+
+	// retry policy, every variable overlays a single field
+	var overlays []func(rp *EnvoyRetryPolicy)
+	var totalTimeoutSet, perTryTimeoutSet bool
+	r.uint("MAX_RETRIES", 16, func(n uint64) {
+		overlays = append(overlays, func(rp *EnvoyRetryPolicy) { rp.MaxRetries = uint16(n) })
+	})
+	r.duration("TOTAL_TIMEOUT", func(d time.Duration) {
+		totalTimeoutSet = true
+		overlays = append(overlays, func(rp *EnvoyRetryPolicy) { rp.TotalTimeout = d })
+	})
+	r.duration("PER_TRY_TIMEOUT", func(d time.Duration) {
+		perTryTimeoutSet = true
+		overlays = append(overlays, func(rp *EnvoyRetryPolicy) { rp.PerTryTimeout = d })
+	})
+	r.list("RETRY_ON", func(items []string) {
+		name := r.prefix + "RETRY_ON"
+		retryOn := make([]RetryOnCode, len(items))
+		for i, item := range items {
+			if err := validateRetryOnCode(RetryOnCode(item)); err != nil {
+				r.fail(name, err)
+				return
+			}
+			retryOn[i] = RetryOnCode(item)
+		}
+		overlays = append(overlays, func(rp *EnvoyRetryPolicy) { rp.RetryOn = retryOn })
+	})
+	r.list("RETRIABLE_STATUS_CODES", func(items []string) {
+		name := r.prefix + "RETRIABLE_STATUS_CODES"
+		codes := make([]uint16, 0, len(items))
+		for _, item := range items {
+			code, err := strconv.ParseUint(item, 10, 16)
+			if err != nil || code < 100 || code > 599 {
+				r.fail(name, fmt.Errorf("invalid status code %q", item))
+				return
+			}
+			codes = append(codes, uint16(code))
+		}
+		overlays = append(overlays, func(rp *EnvoyRetryPolicy) { rp.RetriableStatusCodes = codes })
+	})
+	r.list("RETRIABLE_HEADERS", func(items []string) {
+		overlays = append(overlays, func(rp *EnvoyRetryPolicy) { rp.RetriableHeaders = items })
+	})
+	r.string("RETRY_BACKOFF", func(v string) error { return validateBackoffStrategy(BackoffStrategy(v)) }, func(v string) {
+		overlays = append(overlays, func(rp *EnvoyRetryPolicy) { rp.Backoff = BackoffStrategy(v) })
+	})
+	r.duration("RETRY_BASE_INTERVAL", func(d time.Duration) {
+		overlays = append(overlays, func(rp *EnvoyRetryPolicy) { rp.BaseInterval = d })
+	})
+	r.duration("RETRY_MAX_INTERVAL", func(d time.Duration) {
+		overlays = append(overlays, func(rp *EnvoyRetryPolicy) { rp.MaxInterval = d })
+	})
+	if len(overlays) > 0 {
+		// the combinations of the variables are checked here too, so that they are reported with the rest. the
+		// checks that need the fields of the earlier layers are left to WithEnvoyRetryPolicy.
+		var rp EnvoyRetryPolicy
+		for _, overlay := range overlays {
+			overlay(&rp)
+		}
+		if !totalTimeoutSet {
+			rp.PerTryTimeout = 0
+		}
+		if !totalTimeoutSet || !perTryTimeoutSet {
+			rp.MaxRetries = 0
+		}
+		if err := validateEnvoyRetryPolicy(rp); err != nil {
+			r.errs = append(r.errs, fmt.Errorf("retry policy: %w", err))
+		}
+		co.StaticRequestOptions = append(co.StaticRequestOptions, overlayEnvoyRetryPolicy(overlays...))
+	}
+	r.string("RETRY_MODE", func(v string) error { return validateRetryMode(RetryMode(v)) }, func(v string) {
+		co.StaticRequestOptions = append(co.StaticRequestOptions, WithRetryMode(RetryMode(v)))
+	})
+
+	if len(r.errs) > 0 {
+		return ConfigOptions{}, &EnvConfigError{Errors: r.errs}
+	}
+	return co, nil
+}
+
+func envPrefix(prefix string) string {
+	prefix = strings.Trim(strings.ToUpper(prefix), "_")
+	if prefix == "" {
+		return "HTTP_"
+	}
+	return prefix + "_HTTP_"
+}
+
+// overlayEnvoyRetryPolicy applies the overlays on top of the retry policy set by the earlier options
+// (or an empty policy) and sets the result with WithEnvoyRetryPolicy, so it gets validated as a whole.
+func overlayEnvoyRetryPolicy(overlays ...func(rp *EnvoyRetryPolicy)) StaticRequestOption {
+	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
+		var rp EnvoyRetryPolicy
+		if c.RetryPolicy != nil {
+			rp = c.RetryPolicy.Clone()
+		}
+		for _, overlay := range overlays {
+			overlay(&rp)
+		}
+		return WithEnvoyRetryPolicy(rp)(c)
+	}
+}
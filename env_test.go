@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	co, err := configFromEnv("payments", envLookup(map[string]string{
+		"PAYMENTS_HTTP_HOST":        "payments.internal:8443",
+		"PAYMENTS_HTTP_TIMEOUT":     "750ms",
+		"PAYMENTS_HTTP_MAX_RETRIES": "2",
+		"PAYMENTS_HTTP_RETRY_MODE":  "in-process",
+		"OTHER_HTTP_HOST":           "other.internal:80",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defaults := ConfigOptions{
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL("http://localhost:8080"),
+			WithDefaultEnvoyRetryPolicy(),
+		},
+	}
+	cfg, err := NewConfig(MergeConfigOptions(defaults, co))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ClientConfig.ClientTimeout != 750*time.Millisecond {
+		t.Errorf("expected %v, got %v", 750*time.Millisecond, cfg.ClientConfig.ClientTimeout)
+	}
+	src := cfg.StaticRequestConfig
+	if src.Scheme != "http" || src.Host != "payments.internal:8443" {
+		t.Errorf("expected env to override the host only, got %v %v", src.Scheme, src.Host)
+	}
+	// the env overlays the default retry policy instead of replacing it
+	if src.RetryPolicy.MaxRetries != 2 || src.RetryPolicy.PerTryTimeout != DefaultEnvoyRetryPolicy().PerTryTimeout {
+		t.Errorf("expected max retries to be overlaid on the default policy, got %+v", src.RetryPolicy)
+	}
+	if v := src.Headers.Get("x-envoy-max-retries"); v != "2" {
+		t.Errorf("expected %v, got %v", "2", v)
+	}
+	if src.RetryMode != RetryModeInProcess {
+		t.Errorf("expected %v, got %v", RetryModeInProcess, src.RetryMode)
+	}
+}
+
+func TestConfigFromEnvReportsAllErrors(t *testing.T) {
+	_, err := configFromEnv("payments", envLookup(map[string]string{
+		"PAYMENTS_HTTP_HOST":          "payments.internal",
+		"PAYMENTS_HTTP_TIMEOUT":       "fast",
+		"PAYMENTS_HTTP_MAX_RETRIES":   "-1",
+		"PAYMENTS_HTTP_SCHEME":        "https",
+		"PAYMENTS_HTTP_TLS_CERT_FILE": "cert.pem",
+	}))
+	var envErr *EnvConfigError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("expected env config error, got %v", err)
+	}
+	if len(envErr.Errors) != 4 {
+		t.Errorf("expected %v errors, got %v", 4, envErr.Errors)
+	}
+	for _, name := range []string{"PAYMENTS_HTTP_HOST", "PAYMENTS_HTTP_TIMEOUT", "PAYMENTS_HTTP_MAX_RETRIES", "PAYMENTS_HTTP_TLS_CERT_FILE"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected %v to be reported, got %v", name, err)
+		}
+	}
+}
+
+func TestConfigFromEnvReportsRetryPolicyErrors(t *testing.T) {
+	_, err := configFromEnv("payments", envLookup(map[string]string{
+		"PAYMENTS_HTTP_MAX_RETRIES":     "two",
+		"PAYMENTS_HTTP_TOTAL_TIMEOUT":   "1s",
+		"PAYMENTS_HTTP_PER_TRY_TIMEOUT": "2s",
+		"PAYMENTS_HTTP_RETRY_ON":        "5xx,5xxx",
+	}))
+	var envErr *EnvConfigError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("expected env config error, got %v", err)
+	}
+	if len(envErr.Errors) != 3 {
+		t.Errorf("expected %v errors, got %v", 3, envErr.Errors)
+	}
+	for _, expected := range []string{"PAYMENTS_HTTP_MAX_RETRIES", `PAYMENTS_HTTP_RETRY_ON: unknown retry on code "5xxx"`, "totalTimeout(1000000000) is less than perTryTimeout(2000000000)"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %v to be reported, got %v", expected, err)
+		}
+	}
+	// the individual errors can be matched on go 1.19 too
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) || !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected the errors to be matchable, got %v", err)
+	}
+
+	// the timeouts of the earlier layers aren't known, a partial policy is not rejected up front
+	_, err = configFromEnv("payments", envLookup(map[string]string{
+		"PAYMENTS_HTTP_MAX_RETRIES":     "2",
+		"PAYMENTS_HTTP_PER_TRY_TIMEOUT": "2s",
+	}))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// only the beginning of an unexpected response body is kept in the error, enough to carry an error message
+const maxErrorBodySnippet = 1 << 10
+
+// at most this much of an unexpected response body is read, to decode it with WithErrorResponseJSON
+const maxErrorResponseBody = 1 << 16
+
+// StatusError is returned by Send when the upstream responds with a status code that isn't accepted.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body holds at most the first maxErrorBodySnippet bytes of the response body
+	Body   []byte
+	Method string
+	// URL is redacted, i.e. it never contains the user info of the request
+	URL string
+	// ErrorResponse is the target given with WithErrorResponseJSON, set only if the body was decoded into it
+	ErrorResponse interface{}
+	// RetrySkipped is why the response wasn't retried although the retry policy allows it, e.g.
+	// ErrBodyNotReplayable. it is matched by errors.Is
+	RetrySkipped error
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: unexpected status %d", e.Method, e.URL, e.StatusCode)
+	if e.RetrySkipped != nil {
+		msg += fmt.Sprintf(" (not retried: %v)", e.RetrySkipped)
+	}
+	if len(e.Body) > 0 {
+		msg += ": " + string(e.Body)
+	}
+	return msg
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.RetrySkipped
+}
+
+// TransportError is returned when the request couldn't be sent or the response couldn't be read,
+// e.g. dns, connect, tls failures, connection resets and timeouts.
+type TransportError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	// go std client errors already carry the method and url, don't repeat them
+	err := e.Err
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return fmt.Sprintf("%s %s: %v", e.Method, e.URL, err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned when the response body couldn't be decoded into the response destination.
+type DecodeError struct {
+	Method      string
+	URL         string
+	StatusCode  int
+	ContentType string
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s %s: decoding %q response with status %d: %v", e.Method, e.URL, e.ContentType, e.StatusCode, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrResponseTooLarge is matched by the errors of responses larger than the limit set with WithMaxResponseBytes,
+// the error itself is a *ResponseTooLargeError.
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// ResponseTooLargeError is returned when the response body is larger than the limit, it is detected up front
+// from the Content-Length header when the upstream sends one, otherwise while reading the body.
+type ResponseTooLargeError struct {
+	Limit int64
+	// ContentLength is the announced length of the body, -1 if the limit was exceeded while reading
+	ContentLength int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	if e.ContentLength < 0 {
+		return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+	}
+	return fmt.Sprintf("response body of %d bytes exceeds the limit of %d bytes", e.ContentLength, e.Limit)
+}
+
+func (e *ResponseTooLargeError) Is(target error) bool {
+	return target == ErrResponseTooLarge
+}
+
+// ErrReadIdleTimeout is returned when a read of the response body blocks for longer than the read idle timeout,
+// it is a timeout according to IsTimeout.
+var ErrReadIdleTimeout error = readIdleTimeoutError{}
+
+type readIdleTimeoutError struct{}
+
+func (readIdleTimeoutError) Error() string   { return "response body read idle timeout" }
+func (readIdleTimeoutError) Timeout() bool   { return true }
+func (readIdleTimeoutError) Temporary() bool { return true }
+
+type ConfigKind string
+
+const (
+	ConfigKindClient         ConfigKind = "client"
+	ConfigKindStaticRequest  ConfigKind = "static request"
+	ConfigKindRuntimeRequest ConfigKind = "runtime request"
+)
+
+// ConfigError is returned when an option is invalid or the options don't make a valid config together.
+// Index is the position of the failing option among the options of its kind, -1 if no single option is at fault.
+type ConfigError struct {
+	Kind  ConfigKind
+	Index int
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("invalid %s config: %v", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("invalid %s option at index %d: %v", e.Kind, e.Index, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCodeOf returns the status code of a StatusError in the chain of err, 0 otherwise.
+func StatusCodeOf(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+// IsTimeout reports whether err is due to a timeout, either of the client, the context or the network.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return StatusCodeOf(err) == http.StatusGatewayTimeout || StatusCodeOf(err) == http.StatusRequestTimeout
+}
+
+// IsRetriable reports whether retrying the request might succeed, i.e. rate limits, gateway errors and
+// transport failures that happen before the upstream could process the request.
+func IsRetriable(err error) bool {
+	switch StatusCodeOf(err) {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		return isConnectFailure(err) || isReset(err) || isRefusedStream(err) || IsTimeout(err)
+	}
+	return false
+}
+
+// IsClientError reports whether err is a StatusError with a 4xx status code.
+func IsClientError(err error) bool {
+	code := StatusCodeOf(err)
+	return code >= 400 && code <= 499
+}
+
+// IsServerError reports whether err is a StatusError with a 5xx status code.
+func IsServerError(err error) bool {
+	code := StatusCodeOf(err)
+	return code >= 500 && code <= 599
+}
+
+// redactURL returns the url without the user info, it is safe to put in errors and logs.
+func redactURL(u url.URL) string {
+	if u.User != nil {
+		u.User = url.User("redacted")
+	}
+	return u.String()
+}
@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"not found"}`))
+	}))
+	defer server.Close()
+
+	cfg, err := NewConfig(ConfigOptions{
+		ClientOptions:        []ClientOption{WithGoStdClient(server.Client())},
+		StaticRequestOptions: []StaticRequestOption{WithURL(server.URL)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.StaticRequestConfig.User = "user"
+	cfg.StaticRequestConfig.Password = "secret"
+	hc, err := NewHTTPClientFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = hc.Send(context.Background(), WithPath("/users/1"), WithResponseBody(&bytes.Buffer{}))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusNotFound || statusErr.Method != http.MethodGet {
+		t.Errorf("expected %v %v, got %v %v", http.MethodGet, http.StatusNotFound, statusErr.Method, statusErr.StatusCode)
+	}
+	if statusErr.Header.Get("X-Request-Id") != "abc" || string(statusErr.Body) != `{"error":"not found"}` {
+		t.Errorf("expected the headers and body to be captured, got %v %v", statusErr.Header, string(statusErr.Body))
+	}
+	if strings.Contains(err.Error(), "secret") || strings.Contains(statusErr.URL, "secret") {
+		t.Errorf("expected the password to be redacted, got %v", err)
+	}
+	if !IsClientError(err) || IsServerError(err) || IsRetriable(err) {
+		t.Errorf("expected only IsClientError to be true for %v", err)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	hc := newTestClient(t, server)
+	server.Close()
+
+	err := hc.Send(context.Background(), WithResponseBody(&bytes.Buffer{}))
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		t.Fatalf("expected transport error, got %v", err)
+	}
+	if !IsRetriable(err) {
+		t.Errorf("expected connect failure to be retriable, got %v", err)
+	}
+}
+
+func TestConfigError(t *testing.T) {
+	_, err := NewHTTPClient(ConfigOptions{
+		StaticRequestOptions: []StaticRequestOption{
+			WithScheme("http"),
+			WithHostPort("localhost"),
+		},
+	})
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected config error, got %v", err)
+	}
+	if configErr.Kind != ConfigKindStaticRequest || configErr.Index != 1 {
+		t.Errorf("expected %v option at index %v, got %v at %v", ConfigKindStaticRequest, 1, configErr.Kind, configErr.Index)
+	}
+}
@@ -0,0 +1,389 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileConfig is the serializable form of the client config, meant to be embedded in the config.yaml/json
+// of services so that every service configures its clients the same way. e.g.
+//
+//	scheme: https
+//	host: payments.internal:8443
+//	timeout: 750ms
+//	transport:
+//	  max_idle_conns_per_host: 32
+//	headers:
+//	  x-client-name: checkout
+//	retry_policy:
+//	  mode: in-process
+//	  max_retries: 2
+//	  per_try_timeout: 250ms
+//	  total_timeout: 1s
+//	  retry_on: [connect-failure, gateway-error]
+type FileConfig struct {
+	Scheme      string               `json:"scheme,omitempty"`
+	Host        string               `json:"host,omitempty"`
+	HostHeader  string               `json:"host_header,omitempty"`
+	Timeout     Duration             `json:"timeout,omitempty"`
+	Transport   *FileTransportConfig `json:"transport,omitempty"`
+	TLS         *FileTLSConfig       `json:"tls,omitempty"`
+	Headers     map[string]string    `json:"headers,omitempty"`
+	RetryPolicy *FileRetryPolicy     `json:"retry_policy,omitempty"`
+}
+
+type FileTransportConfig struct {
+	MaxIdleConns        int      `json:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost int      `json:"max_idle_conns_per_host,omitempty"`
+	MaxConnsPerHost     int      `json:"max_conns_per_host,omitempty"`
+	IdleConnTimeout     Duration `json:"idle_conn_timeout,omitempty"`
+	DialTimeout         Duration `json:"dial_timeout,omitempty"`
+	TLSHandshakeTimeout Duration `json:"tls_handshake_timeout,omitempty"`
+}
+
+type FileTLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// FileRetryPolicy mirrors EnvoyRetryPolicy with durations as strings, plus the RetryMode to execute it with.
+type FileRetryPolicy struct {
+	Mode                 RetryMode       `json:"mode,omitempty"`
+	MaxRetries           uint16          `json:"max_retries,omitempty"`
+	TotalTimeout         Duration        `json:"total_timeout,omitempty"`
+	PerTryTimeout        Duration        `json:"per_try_timeout,omitempty"`
+	RetryOn              []RetryOnCode   `json:"retry_on,omitempty"`
+	RetriableStatusCodes []uint16        `json:"retriable_status_codes,omitempty"`
+	RetriableHeaders     []string        `json:"retriable_headers,omitempty"`
+	Backoff              BackoffStrategy `json:"backoff,omitempty"`
+	BaseInterval         Duration        `json:"base_interval,omitempty"`
+	MaxInterval          Duration        `json:"max_interval,omitempty"`
+}
+
+func (rp FileRetryPolicy) envoyRetryPolicy() EnvoyRetryPolicy {
+	return EnvoyRetryPolicy{
+		MaxRetries:           rp.MaxRetries,
+		TotalTimeout:         time.Duration(rp.TotalTimeout),
+		PerTryTimeout:        time.Duration(rp.PerTryTimeout),
+		RetryOn:              rp.RetryOn,
+		RetriableStatusCodes: rp.RetriableStatusCodes,
+		RetriableHeaders:     rp.RetriableHeaders,
+		Backoff:              rp.Backoff,
+		BaseInterval:         time.Duration(rp.BaseInterval),
+		MaxInterval:          time.Duration(rp.MaxInterval),
+	}
+}
+
+// Duration is a time.Duration that is (un)marshalled as a duration string like "750ms" or "2s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"750ms\", got %s", data)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// YAMLNode is implemented by *yaml.Node of gopkg.in/yaml.v3, it keeps this module free of a yaml dependency.
+type YAMLNode interface {
+	Decode(v interface{}) error
+}
+
+// ParseFileConfigJSON decodes and validates the config, unknown keys are rejected with their path.
+func ParseFileConfigJSON(raw []byte) (FileConfig, error) {
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return FileConfig{}, err
+	}
+	if err := checkKnownKeys(generic, reflect.TypeOf(FileConfig{}), ""); err != nil {
+		return FileConfig{}, err
+	}
+	var fc FileConfig
+	if err := json.Unmarshal(raw, &fc); err != nil {
+		return FileConfig{}, err
+	}
+	if err := fc.Validate(); err != nil {
+		return FileConfig{}, err
+	}
+	return fc, nil
+}
+
+// ParseFileConfigYAML decodes and validates the config from a yaml node, see ParseFileConfigJSON.
+func ParseFileConfigYAML(node YAMLNode) (FileConfig, error) {
+	if node == nil {
+		return FileConfig{}, errors.New("yaml node is nil")
+	}
+	var generic interface{}
+	if err := node.Decode(&generic); err != nil {
+		return FileConfig{}, err
+	}
+	generic, err := normalizeYAML(generic, "")
+	if err != nil {
+		return FileConfig{}, err
+	}
+	raw, err := json.Marshal(generic)
+	if err != nil {
+		return FileConfig{}, err
+	}
+	return ParseFileConfigJSON(raw)
+}
+
+func NewHTTPClientFromJSON(raw []byte) (*Client, error) {
+	fc, err := ParseFileConfigJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	return NewHTTPClientFromFileConfig(fc)
+}
+
+func NewHTTPClientFromYAML(node YAMLNode) (*Client, error) {
+	fc, err := ParseFileConfigYAML(node)
+	if err != nil {
+		return nil, err
+	}
+	return NewHTTPClientFromFileConfig(fc)
+}
+
+func NewHTTPClientFromFileConfig(fc FileConfig) (*Client, error) {
+	co, err := fc.ConfigOptions()
+	if err != nil {
+		return nil, err
+	}
+	return NewHTTPClient(co)
+}
+
+// Validate checks the config with the same validations as the options, errors are prefixed with the path of the key.
+func (fc FileConfig) Validate() error {
+	if fc.Scheme != "" {
+		if err := validateScheme(fc.Scheme); err != nil {
+			return fmt.Errorf("scheme: %w", err)
+		}
+	}
+	if fc.Host != "" {
+		if err := validateHostPort(fc.Host); err != nil {
+			return fmt.Errorf("host: %w", err)
+		}
+	}
+	if fc.Timeout < 0 {
+		return errors.New("timeout: cannot be negative")
+	}
+	if t := fc.Transport; t != nil {
+		if t.MaxIdleConns < 0 || t.MaxIdleConnsPerHost < 0 || t.MaxConnsPerHost < 0 {
+			return errors.New("transport: connection pool sizes cannot be negative")
+		}
+		if t.IdleConnTimeout < 0 || t.DialTimeout < 0 || t.TLSHandshakeTimeout < 0 {
+			return errors.New("transport: timeouts cannot be negative")
+		}
+	}
+	if t := fc.TLS; t != nil {
+		if (t.CertFile == "") != (t.KeyFile == "") {
+			return errors.New("tls: cert_file and key_file must be set together")
+		}
+	}
+	for k, v := range fc.Headers {
+		if k == "" || v == "" {
+			return fmt.Errorf("headers.%s: key or value is empty", k)
+		}
+	}
+	if rp := fc.RetryPolicy; rp != nil {
+		if rp.Mode != "" {
+			if err := validateRetryMode(rp.Mode); err != nil {
+				return fmt.Errorf("retry_policy.mode: %w", err)
+			}
+		}
+		for i, code := range rp.RetryOn {
+			if err := validateRetryOnCode(code); err != nil {
+				return fmt.Errorf("retry_policy.retry_on[%d]: %w", i, err)
+			}
+		}
+		if err := validateEnvoyRetryPolicy(rp.envoyRetryPolicy()); err != nil {
+			return fmt.Errorf("retry_policy: %w", err)
+		}
+	}
+	return nil
+}
+
+// ConfigOptions translates the config into the options that NewHTTPClient takes, so that it can be
+// combined with options given in code.
+func (fc FileConfig) ConfigOptions() (ConfigOptions, error) {
+	if err := fc.Validate(); err != nil {
+		return ConfigOptions{}, err
+	}
+	var co ConfigOptions
+
+	if fc.Timeout > 0 {
+		co.ClientOptions = append(co.ClientOptions, WithClientTimeout(time.Duration(fc.Timeout)))
+	}
+	if fc.Transport != nil || fc.TLS != nil {
+		// the transport of an earlier layer is kept, the settings apply on top of it
+		co.ClientOptions = append(co.ClientOptions, ensureTransport())
+	}
+	if t := fc.Transport; t != nil {
+		co.ClientOptions = append(co.ClientOptions, WithConnectionPool(t.MaxIdleConns, t.MaxIdleConnsPerHost, t.MaxConnsPerHost, time.Duration(t.IdleConnTimeout)))
+		if t.DialTimeout > 0 {
+			co.ClientOptions = append(co.ClientOptions, WithDialTimeout(time.Duration(t.DialTimeout)))
+		}
+		if t.TLSHandshakeTimeout > 0 {
+			co.ClientOptions = append(co.ClientOptions, WithTLSHandshakeTimeout(time.Duration(t.TLSHandshakeTimeout)))
+		}
+	}
+	if t := fc.TLS; t != nil {
+		if t.CAFile != "" {
+			co.ClientOptions = append(co.ClientOptions, WithRootCAFile(t.CAFile))
+		}
+		if t.CertFile != "" {
+			co.ClientOptions = append(co.ClientOptions, WithClientCertificateFiles(t.CertFile, t.KeyFile))
+		}
+		if t.ServerName != "" {
+			co.ClientOptions = append(co.ClientOptions, WithTLSServerName(t.ServerName))
+		}
+		co.ClientOptions = append(co.ClientOptions, WithInsecureSkipVerify(t.InsecureSkipVerify))
+	}
+
+	if fc.Scheme != "" {
+		co.StaticRequestOptions = append(co.StaticRequestOptions, WithScheme(fc.Scheme))
+	}
+	if fc.Host != "" {
+		co.StaticRequestOptions = append(co.StaticRequestOptions, WithHostPort(fc.Host))
+	}
+	if fc.HostHeader != "" {
+		co.StaticRequestOptions = append(co.StaticRequestOptions, WithHostHeader(fc.HostHeader))
+	}
+	if len(fc.Headers) > 0 {
+		co.StaticRequestOptions = append(co.StaticRequestOptions, WithStaticHeaders(fc.Headers))
+	}
+	if rp := fc.RetryPolicy; rp != nil {
+		co.StaticRequestOptions = append(co.StaticRequestOptions, WithEnvoyRetryPolicy(rp.envoyRetryPolicy()))
+		if rp.Mode != "" {
+			co.StaticRequestOptions = append(co.StaticRequestOptions, WithRetryMode(rp.Mode))
+		}
+	}
+	return co, nil
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// checkKnownKeys walks the generic json value along with the type it is decoded into and rejects keys
+// that don't map to a field, e.g. "retry_policy.max_retires: unknown key"
+func checkKnownKeys(v interface{}, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil // type mismatches are reported by the json decoder
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ft, ok := fields[k]
+			if !ok {
+				return fmt.Errorf("%s: unknown key", joinPath(path, k))
+			}
+			if err := checkKnownKeys(obj[k], ft, joinPath(path, k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for k, val := range obj {
+			if err := checkKnownKeys(val, t.Elem(), joinPath(path, k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, val := range arr {
+			if err := checkKnownKeys(val, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// normalizeYAML converts the map[interface{}]interface{} that some yaml decoders produce into
+// map[string]interface{} so that the value can be marshalled into json.
+func normalizeYAML(v interface{}, path string) (interface{}, error) {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		obj := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: non string key %v", path, k)
+			}
+			normalized, err := normalizeYAML(item, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = normalized
+		}
+		return obj, nil
+	case map[string]interface{}:
+		for k, item := range val {
+			normalized, err := normalizeYAML(item, joinPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			val[k] = normalized
+		}
+		return val, nil
+	case []interface{}:
+		for i, item := range val {
+			normalized, err := normalizeYAML(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			val[i] = normalized
+		}
+		return val, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,125 @@
+package httpclient
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFileConfigJSON(t *testing.T) {
+	raw := []byte(`{
+		"scheme": "https",
+		"host": "payments.internal:8443",
+		"timeout": "750ms",
+		"transport": {"max_idle_conns_per_host": 32, "dial_timeout": "1s"},
+		"headers": {"x-client-name": "checkout"},
+		"retry_policy": {
+			"mode": "in-process",
+			"max_retries": 2,
+			"per_try_timeout": "250ms",
+			"total_timeout": "1s",
+			"retry_on": ["connect-failure", "gateway-error"]
+		}
+	}`)
+	fc, err := ParseFileConfigJSON(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Duration(fc.Timeout) != 750*time.Millisecond || time.Duration(fc.RetryPolicy.PerTryTimeout) != 250*time.Millisecond {
+		t.Errorf("expected durations to be parsed, got %v %v", fc.Timeout, fc.RetryPolicy.PerTryTimeout)
+	}
+
+	co, err := fc.ConfigOptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg, err := NewConfig(co)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ClientConfig.ClientTimeout != 750*time.Millisecond {
+		t.Errorf("expected %v, got %v", 750*time.Millisecond, cfg.ClientConfig.ClientTimeout)
+	}
+	if cfg.ClientConfig.Transport == nil || cfg.ClientConfig.Transport.MaxIdleConnsPerHost != 32 {
+		t.Errorf("expected transport with 32 idle conns per host, got %v", cfg.ClientConfig.Transport)
+	}
+	src := cfg.StaticRequestConfig
+	if src.Scheme != "https" || src.Host != "payments.internal:8443" || src.Headers.Get("x-client-name") != "checkout" {
+		t.Errorf("unexpected static request config %+v", src)
+	}
+	if src.RetryMode != RetryModeInProcess || src.RetryPolicy == nil || src.RetryPolicy.MaxRetries != 2 {
+		t.Errorf("expected in process retry policy, got %v %+v", src.RetryMode, src.RetryPolicy)
+	}
+}
+
+func TestParseFileConfigJSONErrors(t *testing.T) {
+	testcases := []struct {
+		raw      string
+		expected string
+	}{
+		{raw: `{"hots": "localhost:80"}`, expected: "hots: unknown key"},
+		{raw: `{"retry_policy": {"max_retires": 1}}`, expected: "retry_policy.max_retires: unknown key"},
+		{raw: `{"timeout": 750}`, expected: "duration must be a string"},
+		{raw: `{"timeout": "soon"}`, expected: "invalid duration"},
+		{raw: `{"scheme": "ftp"}`, expected: "scheme: scheme is not http or https"},
+		{raw: `{"host": "localhost"}`, expected: "host: "},
+		{raw: `{"retry_policy": {"max_retries": 1}}`, expected: "retry_policy: maxRetries is set"},
+		{raw: `{"retry_policy": {"mode": "sometimes"}}`, expected: "retry_policy.mode: invalid retry mode"},
+		{raw: `{"retry_policy": {"retry_on": ["5xx", "5xxx"]}}`, expected: `retry_policy.retry_on[1]: unknown retry on code "5xxx"`},
+	}
+	for idx, testcase := range testcases {
+		_, err := ParseFileConfigJSON([]byte(testcase.raw))
+		if err == nil || !strings.Contains(err.Error(), testcase.expected) {
+			t.Errorf("expected error containing %q, got %v for test case %v", testcase.expected, err, idx)
+		}
+	}
+}
+
+// fakeYAMLNode decodes like yaml.v2 does, i.e. into map[interface{}]interface{}
+type fakeYAMLNode map[interface{}]interface{}
+
+func (n fakeYAMLNode) Decode(v interface{}) error {
+	reflect.ValueOf(v).Elem().Set(reflect.ValueOf(map[interface{}]interface{}(n)))
+	return nil
+}
+
+func TestParseFileConfigYAML(t *testing.T) {
+	fc, err := ParseFileConfigYAML(fakeYAMLNode{
+		"host": "localhost:8080",
+		"retry_policy": map[interface{}]interface{}{
+			"retry_on": []interface{}{"5xx"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.Host != "localhost:8080" || !reflect.DeepEqual(fc.RetryPolicy.RetryOn, []RetryOnCode{RetryOn5xx}) {
+		t.Errorf("unexpected file config %+v", fc)
+	}
+
+	_, err = ParseFileConfigYAML(fakeYAMLNode{"transport": map[interface{}]interface{}{"pool": 1}})
+	if err == nil || !strings.Contains(err.Error(), "transport.pool: unknown key") {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestFileConfigKeepsTransportOfEarlierLayers(t *testing.T) {
+	fc, err := ParseFileConfigJSON([]byte(`{"transport": {"max_idle_conns_per_host": 32}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	co, err := fc.ConfigOptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	transport := &http.Transport{ForceAttemptHTTP2: true}
+	cfg, err := NewConfig(MergeConfigOptions(ConfigOptions{ClientOptions: []ClientOption{WithTransport(transport)}}, co))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ClientConfig.Transport != transport || transport.MaxIdleConnsPerHost != 32 {
+		t.Errorf("expected the settings to apply on the transport of the earlier layer, got %+v", cfg.ClientConfig.Transport)
+	}
+}
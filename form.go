@@ -0,0 +1,169 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// WithFormBody sets the body to the url encoded form values.
+func WithFormBody(values url.Values) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		c.Body, c.BodyFunc, c.BodyValue, c.bodyValueSet = []byte(values.Encode()), nil, nil, false
+		c.bodyOneShot, c.bodyPerSend = false, false
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
+		c.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+		return c, nil
+	}
+}
+
+// Part is a part of a multipart/form-data body, either a field or a file
+type Part struct {
+	Name string
+	// Value is the value of a field part, it is ignored for file parts
+	Value string
+	// FileName, ContentType and Reader are set for file parts, ContentType defaults to application/octet-stream
+	FileName    string
+	ContentType string
+	Reader      io.Reader
+}
+
+// FieldPart returns a field part.
+func FieldPart(name, value string) Part {
+	return Part{Name: name, Value: value}
+}
+
+// FilePart returns a file part that streams the file contents from r. the body can be replayed for retries
+// and redirects only if r is an io.Seeker.
+func FilePart(name, fileName, contentType string, r io.Reader) Part {
+	return Part{Name: name, FileName: fileName, ContentType: contentType, Reader: r}
+}
+
+func (p Part) isFile() bool {
+	return p.Reader != nil
+}
+
+// WithMultipart sets the body to a multipart/form-data body of the parts. the body is encoded while it is
+// sent, so large files are never buffered in memory, and the boundary and the Content-Type header are set.
+// the option can be reused across sends, but the readers of the file parts are shared by them, so an option
+// with file parts is meant for a single send. it can't be a default runtime option of the client.
+func WithMultipart(parts ...Part) RuntimeRequestOption {
+	// the boundary is fixed when the option is created, so that every send of the body matches the header
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if len(parts) == 0 {
+			return c, errors.New("no parts given")
+		}
+		for i, part := range parts {
+			if part.Name == "" {
+				return c, fmt.Errorf("part at index %d has no name", i)
+			}
+			if part.isFile() && part.FileName == "" {
+				return c, fmt.Errorf("file part %q has no file name", part.Name)
+			}
+		}
+		// every application of the option is a separate send with its own encoder
+		bodyFunc, oneShot := multipartBodyFunc(boundary, parts)
+		c = withBodyFunc(c, bodyFunc, -1)
+		c.bodyOneShot = oneShot
+		c.bodyPerSend = true
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
+		c.Headers.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+		return c, nil
+	}
+}
+
+// multipartBodyFunc returns the body func of a single send of the parts, it is called again for the retries
+// and redirects of the send.
+func multipartBodyFunc(boundary string, parts []Part) (func() (io.ReadCloser, error), bool) {
+	opens := make([]func() (io.Reader, error), len(parts))
+	oneShot := false
+	for i, part := range parts {
+		if part.isFile() {
+			var partOneShot bool
+			opens[i], partOneShot = replayable(part.Reader)
+			oneShot = oneShot || partOneShot
+		}
+	}
+	var mu sync.Mutex
+	var prev *io.PipeReader
+	var prevDone chan struct{}
+	return func() (io.ReadCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if prev != nil {
+			// the encoder of the previous attempt must be done with the files before they are rewound
+			_ = prev.Close()
+			<-prevDone
+		}
+		// the files are opened before the body is returned, so that a body that can't be replayed fails the send
+		files := make([]io.Reader, len(parts))
+		for i, open := range opens {
+			if open == nil {
+				continue
+			}
+			file, err := open()
+			if err != nil {
+				return nil, err
+			}
+			files[i] = file
+		}
+		pr, pw := io.Pipe()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			// the transport closes the body when it is done with it, which fails the pending writes
+			pw.CloseWithError(writeMultipart(pw, boundary, parts, files))
+		}()
+		prev, prevDone = pr, done
+		return pr, nil
+	}, oneShot
+}
+
+func writeMultipart(w io.Writer, boundary string, parts []Part, files []io.Reader) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for i, part := range parts {
+		if !part.isFile() {
+			if err := mw.WriteField(part.Name, part.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(part.Name), escapeQuotes(part.FileName)))
+		header.Set("Content-Type", contentType)
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(pw, files[i]); err != nil {
+			return fmt.Errorf("reading file part %q: %w", part.Name, err)
+		}
+	}
+	return mw.Close()
+}
+
+// same as the unexported escaping of mime/multipart
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
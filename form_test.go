@@ -0,0 +1,180 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithFormBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if v := r.PostForm.Get("q"); v != "a b&c" {
+			t.Errorf("expected %v, got %v", "a b&c", v)
+		}
+	}))
+	defer server.Close()
+
+	hc := newTestClient(t, server)
+	err := hc.Send(context.Background(), WithMethod(MethodPost), WithFormBody(url.Values{"q": {"a b&c"}}), WithResponseBody(io.Discard))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWithMultipart(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if v := r.MultipartForm.Value["title"]; len(v) != 1 || v[0] != "report" {
+			t.Errorf("expected %v, got %v", []string{"report"}, v)
+		}
+		testCases := []struct {
+			name, fileName, contentType, content string
+		}{
+			{name: "doc", fileName: "report.csv", contentType: "text/csv", content: "a,b\n1,2\n"},
+			{name: "blob", fileName: `we"ird.bin`, contentType: "application/octet-stream", content: "\x00\x01"},
+		}
+		for idx, testCase := range testCases {
+			files := r.MultipartForm.File[testCase.name]
+			if len(files) != 1 {
+				t.Errorf("test case %v: expected one file, got %v", idx, len(files))
+				continue
+			}
+			if files[0].Filename != testCase.fileName {
+				t.Errorf("test case %v: expected %v, got %v", idx, testCase.fileName, files[0].Filename)
+			}
+			if ct := files[0].Header.Get("Content-Type"); ct != testCase.contentType {
+				t.Errorf("test case %v: expected %v, got %v", idx, testCase.contentType, ct)
+			}
+			f, _ := files[0].Open()
+			content, _ := io.ReadAll(f)
+			if string(content) != testCase.content {
+				t.Errorf("test case %v: expected %q, got %q", idx, testCase.content, string(content))
+			}
+		}
+		// the body must be replayed as a whole on retries
+		if atomic.AddInt32(&hits, 1) < 2 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	hc := newRetryingTestClient(t, server)
+	err := hc.Send(context.Background(), WithMethod(MethodPost), WithMultipart(
+		FieldPart("title", "report"),
+		FilePart("doc", "report.csv", "text/csv", strings.NewReader("a,b\n1,2\n")),
+		FilePart("blob", `we"ird.bin`, "", strings.NewReader("\x00\x01")),
+	), WithResponseBody(io.Discard))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hits := atomic.LoadInt32(&hits); hits != 2 {
+		t.Errorf("expected %v, got %v", 2, hits)
+	}
+
+	// a file that can't be rewound is sent only once
+	atomic.StoreInt32(&hits, 0)
+	err = hc.Send(context.Background(), WithMethod(MethodPost), WithMultipart(
+		FieldPart("title", "report"),
+		FilePart("doc", "report.csv", "text/csv", io.MultiReader(strings.NewReader("a,b\n1,2\n"))),
+		FilePart("blob", `we"ird.bin`, "", strings.NewReader("\x00\x01")),
+	), WithResponseBody(io.Discard))
+	if StatusCodeOf(err) != http.StatusBadGateway {
+		t.Errorf("expected a status error, got %v", err)
+	}
+	if hits := atomic.LoadInt32(&hits); hits != 1 {
+		t.Errorf("expected %v, got %v", 1, hits)
+	}
+}
+
+func TestWithMultipartMiddlewareFailsWithoutSending(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	hc := newTestClient(t, server)
+
+	failing := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("circuit open")
+		}
+	}
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		err := hc.Send(context.Background(), WithMethod(MethodPost), WithRequestMiddlewares(failing), WithMultipart(
+			FilePart("doc", "report.csv", "text/csv", strings.NewReader("a,b\n1,2\n")),
+		), WithResponseBody(io.Discard))
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+	// the encoders must not be left blocked on the pipe
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the encoders to exit, %v goroutines are left of %v", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWithMultipartReusedConcurrently(t *testing.T) {
+	big := strings.Repeat("x", 1<<20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(4 << 20); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if v := r.MultipartForm.Value["big"]; len(v) != 1 || len(v[0]) != len(big) {
+			t.Errorf("expected the whole field to be sent")
+		}
+	}))
+	defer server.Close()
+	hc := newTestClient(t, server)
+
+	// a field-only option can be shared by concurrent sends, each send encodes its own body
+	body := WithMultipart(FieldPart("a", "b"), FieldPart("big", big))
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := hc.Send(context.Background(), WithMethod(MethodPost), body, WithResponseBody(io.Discard)); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStreamedBodiesCannotBeDefaults(t *testing.T) {
+	for idx, body := range []RuntimeRequestOption{
+		WithMultipart(FieldPart("a", "b")),
+		WithRequestBodyReader(strings.NewReader("body"), 4),
+	} {
+		_, err := NewConfig(ConfigOptions{RuntimeRequestOptions: []RuntimeRequestOption{body}})
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) {
+			t.Errorf("test case %v: expected config error, got %v", idx, err)
+		}
+	}
+	// a later body replaces the streamed one
+	_, err := NewConfig(ConfigOptions{RuntimeRequestOptions: []RuntimeRequestOption{WithMultipart(FieldPart("a", "b")), WithRequestJSON(1)}})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/textproto"
+)
+
+// requestHeaders returns the headers that a request starts with before its own options apply. the layers
+// are, in increasing precedence: static headers, the client's default runtime headers. a key present in a
+// later layer replaces all the values of the earlier layers, the per request options then Set, Add or Del
+// on top of the result.
+func (st *clientState) requestHeaders() http.Header {
+	static := st.staticRequestConfig.Headers
+	if !st.staticRequestConfig.RetryMode.sidecar() && len(static) > 0 {
+		// there is no sidecar to execute the retry policy, don't advertise it upstream
+		static = static.Clone()
+		for _, header := range envoyRetryPolicyHeaders {
+			static.Del(header)
+		}
+	}
+	return mergeHeaders(static, st.runtimeRequestConfig.Headers)
+}
+
+func mergeHeaders(layers ...http.Header) http.Header {
+	merged := make(http.Header)
+	for _, layer := range layers {
+		for k, vs := range layer {
+			merged[textproto.CanonicalMIMEHeaderKey(k)] = append([]string(nil), vs...)
+		}
+	}
+	return merged
+}
+
+// applyHeaders copies the headers onto the request. the Host header is special cased as go ignores it
+// in req.Header and takes it from req.Host instead.
+func applyHeaders(req *http.Request, headers http.Header) {
+	for k, vs := range headers {
+		if k == "Host" {
+			if len(vs) > 0 {
+				req.Host = vs[0]
+			}
+			continue
+		}
+		req.Header[k] = append([]string(nil), vs...)
+	}
+}
@@ -0,0 +1,92 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHeaderMerging(t *testing.T) {
+	var got http.Header
+	var gotHost string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		gotHost = r.Host
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{WithGoStdClient(server.Client())},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			WithHostHeader("payments.internal"),
+			WithStaticHeader("X-Static", "static"),
+			WithStaticHeader("X-Overridden", "static"),
+			WithAddStaticHeader("X-Multi", "a"),
+			WithAddStaticHeader("X-Multi", "b"),
+			WithDefaultEnvoyRetryPolicy(),
+		},
+		RuntimeRequestOptions: []RuntimeRequestOption{
+			WithRuntimeHeader("X-Overridden", "default-runtime"),
+			WithRuntimeHeader("X-Default", "default-runtime"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = hc.Send(context.Background(), WithResponseBody(&bytes.Buffer{}),
+		WithAddRuntimeHeader("X-Multi", "c"),
+		WithoutHeader("X-Static"),
+		WithRuntimeHeader("X-Call", "call"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotHost != "payments.internal" {
+		t.Errorf("expected %v, got %v", "payments.internal", gotHost)
+	}
+	if v := got.Values("X-Multi"); !reflect.DeepEqual(v, []string{"a", "b", "c"}) {
+		t.Errorf("expected %v, got %v", []string{"a", "b", "c"}, v)
+	}
+	if v := got.Get("X-Overridden"); v != "default-runtime" {
+		t.Errorf("expected %v, got %v", "default-runtime", v)
+	}
+	if v := got.Get("X-Default"); v != "default-runtime" {
+		t.Errorf("expected %v, got %v", "default-runtime", v)
+	}
+	if v := got.Get("X-Call"); v != "call" {
+		t.Errorf("expected %v, got %v", "call", v)
+	}
+	if _, ok := got["X-Static"]; ok {
+		t.Errorf("expected X-Static to be removed")
+	}
+	if v := got.Get("x-envoy-max-retries"); v != "3" {
+		t.Errorf("expected the envoy retry headers to be sent in sidecar mode, got %v", v)
+	}
+
+	// in process retries don't advertise the policy to the upstream
+	hc2, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{WithGoStdClient(server.Client())},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			WithDefaultEnvoyRetryPolicy(),
+			WithRetryMode(RetryModeInProcess),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := hc2.Send(context.Background(), WithResponseBody(&bytes.Buffer{})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, header := range envoyRetryPolicyHeaders {
+		if v := got.Get(header); v != "" {
+			t.Errorf("expected %v to not be sent, got %v", header, v)
+		}
+	}
+}
@@ -3,24 +3,40 @@ package httpclient
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sync/atomic"
+	"time"
 )
 
 type Client struct {
-	stdClient            *http.Client
+	// state is swapped as a whole on Reload, every request works on the state it loaded when it started
+	state atomic.Pointer[clientState]
+}
+
+// clientState is everything a request needs from the client. the go std client (and so the connection pool)
+// is shared across the states.
+type clientState struct {
+	stdClient *http.Client
+	// customStdClient is set when the go std client was given with WithGoStdClient instead of built by us
+	customStdClient      bool
+	middlewares          []Middleware
 	staticRequestConfig  StaticRequestConfig
 	runtimeRequestConfig RuntimeRequestConfig
+	// timeout is enforced per request through the context, so that it can be changed without touching the
+	// shared go std client. it is zero for custom go std clients, they bring their own timeout.
+	timeout time.Duration
 }
 
 func NewHTTPClientFromConfig(cfg Config) (*Client, error) {
 	if cfg.ClientConfig.Client != nil && cfg.ClientConfig.Transport != nil {
-		return nil, fmt.Errorf("cannot set both client and transport")
+		return nil, &ConfigError{Kind: ConfigKindClient, Index: -1, Err: errors.New("cannot set both client and transport")}
 	}
 	var stdClient *http.Client
+	var timeout time.Duration
 	if cfg.ClientConfig.Client != nil {
 		stdClient = cfg.ClientConfig.Client
 	} else {
@@ -30,15 +46,20 @@ func NewHTTPClientFromConfig(cfg Config) (*Client, error) {
 		}
 		stdClient = &http.Client{
 			Transport: transport,
-			Timeout:   cfg.ClientConfig.ClientTimeout,
 		}
+		timeout = cfg.ClientConfig.ClientTimeout
 	}
 
-	return &Client{
+	c := &Client{}
+	c.state.Store(&clientState{
 		stdClient:            stdClient,
+		customStdClient:      cfg.ClientConfig.Client != nil,
+		middlewares:          cfg.ClientConfig.Middlewares,
 		staticRequestConfig:  cfg.StaticRequestConfig,
 		runtimeRequestConfig: cfg.RuntimeRequestConfig,
-	}, nil
+		timeout:              timeout,
+	})
+	return c, nil
 }
 
 func NewHTTPClient(co ConfigOptions) (*Client, error) {
@@ -50,84 +71,269 @@ func NewHTTPClient(co ConfigOptions) (*Client, error) {
 }
 
 func (c *Client) Send(ctx context.Context, opts ...RuntimeRequestOption) error {
-	rrc, err := buildRuntimeRequestConfig(c.runtimeRequestConfig, opts...)
+	st := c.state.Load()
+	rrc, err := st.buildRuntimeRequestConfig(opts...)
 	if err != nil {
 		return err
 	}
-
-	// validate that the response destination is given
-	if rrc.ResponseJSON == nil && rrc.ResponseBody == nil {
-		return fmt.Errorf("no response destination given")
-	} else if rrc.ResponseJSON != nil && rrc.ResponseBody != nil {
-		return fmt.Errorf("cannot set both response json and response body")
+	if err := validateResponseDestination(rrc); err != nil {
+		return &ConfigError{Kind: ConfigKindRuntimeRequest, Index: -1, Err: err}
 	}
+	setAccept(st.staticRequestConfig, rrc)
 
-	req, err := c.buildRequest(ctx, rrc)
+	resp, err := st.do(ctx, rrc)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
-	resp, err := c.stdClient.Do(req)
-	if err != nil {
-		return err // todo: figure out how to extract url from request and add to error
+	if !statusInRanges(resp.StatusCode, expectedStatus(st.staticRequestConfig, rrc)) {
+		// error responses are small, only a bounded prefix is read so that a misbehaving upstream can't
+		// make us buffer a huge body
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorResponseBody))
+		statusErr := st.statusError(rrc, resp, body)
+		if target := errorResponseTarget(rrc, resp.StatusCode); target != nil && len(body) > 0 {
+			// the error response is best effort, the status error is returned regardless
+			codec := codecFor(st.staticRequestConfig.Codecs, JSONCodec.ContentType())
+			if codec.Unmarshal(body, target) == nil {
+				statusErr.ErrorResponse = target
+			}
+		}
+		return statusErr
 	}
-	defer resp.Body.Close()
-	// if no error is returned, the response will contain a non-nil resp.Body which the user is expected to close.
-	// so we can read the body here and return the error if any
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	if rrc.ResponseHandler != nil {
+		return rrc.ResponseHandler(resp)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("not ok error code: %d", resp.StatusCode) // todo: error context with urlStr
+	if hasNoBody(requestMethod(rrc), resp) {
+		return nil
 	}
 	// todo: response body contract validations
 	// todo: request body contract validations
-	if c.runtimeRequestConfig.ResponseJSON == nil {
-		_, err := io.Copy(c.runtimeRequestConfig.ResponseBody, bytes.NewReader(body))
-		if err != nil {
-			return err
+
+	// the body is streamed into the destination, read errors are told apart from the errors of the destination
+	body := &readErrorReader{r: resp.Body}
+	if rrc.ResponseBody != nil {
+		_, err := io.Copy(rrc.ResponseBody, body)
+		if body.err != nil {
+			return st.transportError(rrc, body.err)
 		}
-	} else {
-		err := json.Unmarshal(body, c.runtimeRequestConfig.ResponseJSON)
-		if err != nil {
-			return err
+		return err
+	}
+	target, _ := responseTarget(rrc)
+	codec, err := responseCodec(st.staticRequestConfig, rrc, resp)
+	if err != nil {
+		return st.decodeError(rrc, resp, err)
+	}
+	err = codec.NewDecoder(body).Decode(target)
+	if body.err != nil {
+		return st.transportError(rrc, body.err)
+	}
+	if err == io.EOF {
+		// empty body, nothing to decode
+		return nil
+	}
+	if err != nil {
+		return st.decodeError(rrc, resp, err)
+	}
+	return nil
+}
+
+// validateResponseDestination validates that exactly one response destination is given
+func validateResponseDestination(rrc RuntimeRequestConfig) error {
+	n := 0
+	destinations := []bool{
+		rrc.ResponseJSON != nil, rrc.ResponseXML != nil, rrc.ResponseProto != nil, rrc.Response != nil,
+		rrc.ResponseBody != nil, rrc.ResponseHandler != nil,
+	}
+	for _, set := range destinations {
+		if set {
+			n++
 		}
 	}
+	if n == 0 {
+		return errors.New("no response destination given")
+	} else if n > 1 {
+		return errors.New("only one of response json, response xml, response proto, response, response body and response handler can be set")
+	}
 	return nil
 }
-func buildRuntimeRequestConfig(rrc RuntimeRequestConfig, opts ...RuntimeRequestOption) (RuntimeRequestConfig, error) {
-	for _, opt := range opts {
+
+// readErrorReader records the error of the underlying reader, io.EOF is not an error
+type readErrorReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readErrorReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// SendRaw sends the request and returns the response as is without checking the status code.
+// it is useful when the caller needs the status, headers or wants to consume the body in its own way.
+// the caller must close the returned response.
+func (c *Client) SendRaw(ctx context.Context, opts ...RuntimeRequestOption) (*Response, error) {
+	st := c.state.Load()
+	rrc, err := st.buildRuntimeRequestConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := st.do(ctx, rrc)
+	if err != nil {
+		return nil, err
+	}
+	return newResponse(resp), nil
+}
+
+// streamState returns the state of the client for long lived responses. neither the client timeout nor the
+// timeouts of the in process retry policy apply to them, they would cut the response off while it is read.
+func (c *Client) streamState() *clientState {
+	st := *c.state.Load()
+	st.timeout = 0
+	if policy := st.staticRequestConfig.RetryPolicy; policy != nil {
+		streamPolicy := *policy
+		streamPolicy.TotalTimeout, streamPolicy.PerTryTimeout = 0, 0
+		st.staticRequestConfig.RetryPolicy = &streamPolicy
+	}
+	return &st
+}
+
+// openStream sends the request and returns the response if its status is expected, for the callers that
+// consume the body incrementally.
+func (st *clientState) openStream(ctx context.Context, rrc RuntimeRequestConfig) (*http.Response, error) {
+	resp, err := st.do(ctx, rrc)
+	if err != nil {
+		return nil, err
+	}
+	if !statusInRanges(resp.StatusCode, expectedStatus(st.staticRequestConfig, rrc)) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySnippet))
+		_ = resp.Body.Close()
+		return nil, st.statusError(rrc, resp, body)
+	}
+	return resp, nil
+}
+
+// do builds the request and sends it, the returned response body is expected to be closed by the caller.
+func (st *clientState) do(ctx context.Context, rrc RuntimeRequestConfig) (*http.Response, error) {
+	roundTrip := chainMiddlewares(st.stdClient.Do, append(st.middlewares[:len(st.middlewares):len(st.middlewares)], rrc.Middlewares...)...)
+
+	// without an in process retry policy, the request is sent exactly once
+	var policy EnvoyRetryPolicy
+	if st.staticRequestConfig.RetryPolicy != nil && st.staticRequestConfig.RetryMode.inProcess() {
+		policy = *st.staticRequestConfig.RetryPolicy
+	}
+
+	ctx = context.WithValue(ctx, requestScopeKey{}, &requestScope{})
+	if rrc.RouteTemplate != "" {
+		ctx = context.WithValue(ctx, routeTemplateContextKey{}, rrc.RouteTemplate)
+	}
+	var cancels []context.CancelFunc
+	if st.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, st.timeout)
+		cancels = append(cancels, cancel)
+	}
+	// the read idle timeout cancels the request when the body stalls
+	ctx, cancel := context.WithCancel(ctx)
+	cancels = append(cancels, cancel)
+	cancelAll := func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+
+	// compressed responses are decompressed here instead of the transport, so that it works for any transport.
+	// same as the go std transport, the response is left alone when the caller asked for an encoding, and range
+	// requests don't ask for one as a compressed part of a body can't be decompressed.
+	decompress := rrc.Headers.Get("Accept-Encoding") == "" && rrc.Headers.Get("Range") == "" && requestMethod(rrc) != http.MethodHead
+	if decompress {
+		rrc.Headers = rrc.Headers.Clone()
+		rrc.Headers.Set("Accept-Encoding", acceptEncoding())
+	}
+
+	resp, err := st.sendWithRetries(ctx, rrc, policy, roundTrip)
+	if err != nil {
+		cancelAll()
+		var configErr *ConfigError
+		if errors.As(err, &configErr) {
+			return nil, err
+		}
+		return nil, st.transportError(rrc, err)
+	}
+	limit := maxResponseBytes(st.staticRequestConfig, rrc)
+	// compressed bodies are checked too, decompressing them only makes them larger in practice
+	if limit > 0 && resp.ContentLength > limit {
+		// the body is not drained, it is too large to be worth keeping the connection
+		_ = resp.Body.Close()
+		cancelAll()
+		return nil, st.transportError(rrc, &ResponseTooLargeError{Limit: limit, ContentLength: resp.ContentLength})
+	}
+	if decompress {
+		decompressResponse(resp)
+	}
+	if limit > 0 {
+		// the limit applies to the decompressed body, so that a small compressed body can't blow up in memory
+		resp.Body = &limitedBody{ReadCloser: resp.Body, limit: limit}
+	}
+	if idleTimeout := readIdleTimeout(st.staticRequestConfig, rrc); idleTimeout > 0 {
+		resp.Body = newIdleTimeoutBody(resp.Body, idleTimeout, cancel)
+	}
+	if recorder := timingsOf(resp); recorder != nil {
+		resp.Body = &timingsBody{ReadCloser: resp.Body, recorder: recorder, hook: rrc.TimingsHook}
+	}
+	// the timeout covers reading the body, same as the timeout of the go std client
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancels: cancels}
+	return resp, nil
+}
+
+func (st *clientState) buildRuntimeRequestConfig(opts ...RuntimeRequestOption) (RuntimeRequestConfig, error) {
+	// the client's runtime config is shared by all the requests, options must only touch a copy of it
+	rrc := st.runtimeRequestConfig.Clone()
+	rrc.Headers = st.requestHeaders()
+	for idx, opt := range opts {
 		var err error
 		rrc, err = opt(rrc)
 		if err != nil {
-			return RuntimeRequestConfig{}, err
+			return RuntimeRequestConfig{}, &ConfigError{Kind: ConfigKindRuntimeRequest, Index: idx, Err: err}
 		}
 	}
+	rrc, err := st.encodeBody(rrc)
+	if err == nil {
+		rrc, err = compressBody(rrc)
+	}
+	if err != nil {
+		return RuntimeRequestConfig{}, &ConfigError{Kind: ConfigKindRuntimeRequest, Index: -1, Err: err}
+	}
 	return rrc, nil
 }
 
-func (c *Client) buildRequest(ctx context.Context, rrc RuntimeRequestConfig) (*http.Request, error) {
+func (st *clientState) buildRequest(ctx context.Context, rrc RuntimeRequestConfig) (*http.Request, error) {
 	var rqBody io.Reader = nil
 	if rrc.Body != nil {
 		rqBody = bytes.NewReader(rrc.Body)
 	}
 
-	urlStr := buildURLString(c.staticRequestConfig, rrc)
+	u := buildURL(st.staticRequestConfig, rrc)
 
 	rq, err := http.NewRequestWithContext(
-		ctx, string(c.runtimeRequestConfig.Method),
-		urlStr,
+		ctx, string(rrc.Method),
+		u.String(),
 		rqBody,
 	)
 	if err != nil {
-		return nil, err // todo: error context with urlStr
+		return nil, &ConfigError{Kind: ConfigKindRuntimeRequest, Index: -1, Err: fmt.Errorf("building request for %s: %w", redactURL(u), err)}
+	}
+	if err := setRequestBody(rq, rrc); err != nil {
+		return nil, err
 	}
+	applyHeaders(rq, rrc.Headers)
 	return rq, nil
 }
 
-func buildURLString(staticRequestConfig StaticRequestConfig, runtimeRequestConfig RuntimeRequestConfig) string {
+func buildURL(staticRequestConfig StaticRequestConfig, runtimeRequestConfig RuntimeRequestConfig) url.URL {
 	var userinfo *url.Userinfo
 	if staticRequestConfig.User != "" && staticRequestConfig.Password != "" {
 		userinfo = url.UserPassword(staticRequestConfig.User, staticRequestConfig.Password)
@@ -141,9 +347,46 @@ func buildURLString(staticRequestConfig StaticRequestConfig, runtimeRequestConfi
 		Path:     runtimeRequestConfig.Path,
 		RawQuery: runtimeRequestConfig.Query.Encode(),
 	}
-	return u.String()
+	return u
 }
 
-// // todo: have some other api where response consumption is very easy and intuitive
-// func (c *Client) SendRaw(ctx context.Context, opts ...RuntimeRequestOption) (*http.Response, error) {
-// }
+// requestMethod returns the method that the go std client ends up using for the request
+func requestMethod(rrc RuntimeRequestConfig) string {
+	if rrc.Method == "" {
+		return http.MethodGet
+	}
+	return string(rrc.Method)
+}
+
+func (st *clientState) transportError(rrc RuntimeRequestConfig, err error) error {
+	return &TransportError{
+		Method: requestMethod(rrc),
+		URL:    redactURL(buildURL(st.staticRequestConfig, rrc)),
+		Err:    err,
+	}
+}
+
+func (st *clientState) statusError(rrc RuntimeRequestConfig, resp *http.Response, body []byte) *StatusError {
+	if len(body) > maxErrorBodySnippet {
+		body = body[:maxErrorBodySnippet]
+	}
+	return &StatusError{
+		StatusCode:   resp.StatusCode,
+		Status:       resp.Status,
+		Header:       resp.Header,
+		Body:         body,
+		Method:       requestMethod(rrc),
+		URL:          redactURL(buildURL(st.staticRequestConfig, rrc)),
+		RetrySkipped: retrySkippedOf(resp),
+	}
+}
+
+func (st *clientState) decodeError(rrc RuntimeRequestConfig, resp *http.Response, err error) error {
+	return &DecodeError{
+		Method:      requestMethod(rrc),
+		URL:         redactURL(buildURL(st.staticRequestConfig, rrc)),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Err:         err,
+	}
+}
@@ -0,0 +1,197 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestClient(t *testing.T, server *httptest.Server, opts ...RuntimeRequestOption) *Client {
+	t.Helper()
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{
+			WithGoStdClient(server.Client()),
+		},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+		},
+		RuntimeRequestOptions: opts,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return hc
+}
+
+func TestSendRaw(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected %v, got %v", http.MethodPost, r.Method)
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Trailer", "X-Checksum")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":7}`))
+		w.Header().Set("X-Checksum", "42")
+	}))
+	defer server.Close()
+
+	hc := newTestClient(t, server)
+	resp, err := hc.SendRaw(context.Background(), WithMethod(MethodPost), WithPath("/items"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected %v, got %v", http.StatusCreated, resp.StatusCode)
+	}
+	if v := resp.Header.Get("ETag"); v != `"abc"` {
+		t.Errorf("expected %v, got %v", `"abc"`, v)
+	}
+	if resp.URL == nil || resp.URL.Path != "/items" {
+		t.Errorf("expected final url path to be /items, got %v", resp.URL)
+	}
+
+	var body struct {
+		ID int `json:"id"`
+	}
+	if err := resp.JSON(&body); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body.ID != 7 {
+		t.Errorf("expected %v, got %v", 7, body.ID)
+	}
+	// the body is cached once read
+	str, err := resp.String()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if str != `{"id":7}` {
+		t.Errorf("expected %v, got %v", `{"id":7}`, str)
+	}
+	if v := resp.Trailer().Get("X-Checksum"); v != "42" {
+		t.Errorf("expected %v, got %v", "42", v)
+	}
+}
+
+// signalWriter closes written on the first write
+type signalWriter struct {
+	written chan struct{}
+	n       int
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		close(w.written)
+	}
+	w.n += len(p)
+	return len(p), nil
+}
+
+func TestSendStreamsResponseBody(t *testing.T) {
+	w := &signalWriter{written: make(chan struct{})}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte("first chunk"))
+		rw.(http.Flusher).Flush()
+		// the rest is written only once the first chunk reached the destination, so Send must not buffer
+		<-w.written
+		_, _ = rw.Write([]byte("second chunk"))
+	}))
+	defer server.Close()
+
+	hc := newTestClient(t, server)
+	if err := hc.Send(context.Background(), WithResponseBody(w)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := len("first chunk") + len("second chunk"); w.n != expected {
+		t.Errorf("expected %v, got %v", expected, w.n)
+	}
+}
+
+func TestSendResponseJSON(t *testing.T) {
+	testCases := []struct {
+		body      string
+		expected  map[string]int
+		decodeErr bool
+	}{
+		{body: `{"a":1}`, expected: map[string]int{"a": 1}},
+		{body: "{\"a\":1}\n\t ", expected: map[string]int{"a": 1}},
+		{body: "", expected: nil},
+		{body: `{"a":1}{"a":2}`, decodeErr: true},
+		{body: `{"a":`, decodeErr: true},
+	}
+	for idx, testCase := range testCases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(testCase.body))
+		}))
+		hc := newTestClient(t, server)
+		var resp map[string]int
+		err := hc.Send(context.Background(), WithResponseJSON(&resp))
+		server.Close()
+
+		var decodeErr *DecodeError
+		if testCase.decodeErr {
+			if !errors.As(err, &decodeErr) {
+				t.Errorf("test case %v: expected a decode error, got %v", idx, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test case %v: unexpected error: %v", idx, err)
+			continue
+		}
+		if len(resp) != len(testCase.expected) || resp["a"] != testCase.expected["a"] {
+			t.Errorf("test case %v: expected %v, got %v", idx, testCase.expected, resp)
+		}
+	}
+}
+
+func TestSendResponseHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("raw"))
+	}))
+	defer server.Close()
+
+	hc := newTestClient(t, server)
+	var got string
+	err := hc.Send(context.Background(), WithResponseHandler(func(resp *http.Response) error {
+		body, err := io.ReadAll(resp.Body)
+		got = string(body)
+		return err
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "raw" {
+		t.Errorf("expected %v, got %v", "raw", got)
+	}
+
+	handlerErr := errors.New("handler failed")
+	err = hc.Send(context.Background(), WithResponseHandler(func(resp *http.Response) error { return handlerErr }))
+	if err != handlerErr {
+		t.Errorf("expected %v, got %v", handlerErr, err)
+	}
+
+	called := false
+	err = hc.Send(context.Background(), WithPath("/missing"), WithResponseHandler(func(resp *http.Response) error {
+		called = true
+		return nil
+	}))
+	if StatusCodeOf(err) != http.StatusNotFound || called {
+		t.Errorf("expected a status error without calling the handler, got %v", err)
+	}
+
+	var configErr *ConfigError
+	err = hc.Send(context.Background(), WithResponseBody(io.Discard), WithResponseHandler(func(resp *http.Response) error { return nil }))
+	if !errors.As(err, &configErr) {
+		t.Errorf("expected a config error, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// lines longer than this fail the stream, so that a response without line breaks can't grow the memory use
+const maxJSONLine = 4 << 20
+
+// JSONLines is a newline delimited json (NDJSON, JSON lines) response, it decodes one value at a time so the
+// memory use doesn't grow with the length of the response.
+type JSONLines[T any] struct {
+	resp   *http.Response
+	st     *clientState
+	rrc    RuntimeRequestConfig
+	codec  Codec
+	reader *bufio.Reader
+	line   []byte
+	lineNo int
+	err    error
+}
+
+// LineError is returned by JSONLines.Next when a line can't be decoded, the caller can call Next again to skip
+// the line or Close to abort.
+type LineError struct {
+	// Line is the 1-based line number in the response body
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("decoding line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// StreamJSONLines sends the request and returns the newline delimited json response, the request is built
+// the same way as with Send. the Accept header defaults to application/x-ndjson and the lines are decoded
+// with the json codec of the client. neither the client timeout nor the timeouts of an in process retry policy
+// apply, the caller must Close the stream. lines longer than 4 MiB fail the stream.
+// e.g.
+//
+//	lines, err := httpclient.StreamJSONLines[Record](ctx, c, WithPath("/export"))
+//	defer lines.Close()
+//	for {
+//		record, err := lines.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+func StreamJSONLines[T any](ctx context.Context, c *Client, opts ...RuntimeRequestOption) (*JSONLines[T], error) {
+	st := c.streamState()
+	rrc, err := st.buildRuntimeRequestConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if rrc.Headers.Get("Accept") == "" {
+		rrc.Headers.Set("Accept", "application/x-ndjson")
+	}
+	resp, err := st.openStream(ctx, rrc)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLines[T]{
+		resp:   resp,
+		st:     st,
+		rrc:    rrc,
+		codec:  codecFor(st.staticRequestConfig.Codecs, JSONCodec.ContentType()),
+		reader: bufio.NewReader(resp.Body),
+	}, nil
+}
+
+// Next decodes the next value, it returns io.EOF after the last one. decoding errors are returned as *LineError
+// and don't end the stream, any other error does and is returned again by the later calls. blank lines are skipped.
+func (l *JSONLines[T]) Next() (T, error) {
+	var value T
+	for l.err == nil {
+		line, err := l.readLine()
+		if err == bufio.ErrTooLong {
+			l.err = l.st.decodeError(l.rrc, l.resp, fmt.Errorf("line %d is longer than %d bytes: %w", l.lineNo+1, maxJSONLine, err))
+			break
+		}
+		if err != nil {
+			if err != io.EOF {
+				err = l.st.transportError(l.rrc, err)
+			}
+			l.err = err
+			break
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := l.codec.Unmarshal(line, &value); err != nil {
+			var zero T
+			return zero, &LineError{Line: l.lineNo, Err: err}
+		}
+		return value, nil
+	}
+	return value, l.err
+}
+
+// readLine returns the next line without the line terminator, the returned slice is reused by the next call
+func (l *JSONLines[T]) readLine() ([]byte, error) {
+	l.line = l.line[:0]
+	for {
+		chunk, err := l.reader.ReadSlice('\n')
+		if len(l.line)+len(chunk) > maxJSONLine {
+			return nil, bufio.ErrTooLong
+		}
+		l.line = append(l.line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(l.line) > 0 {
+			// the last line doesn't need a terminator
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		l.lineNo++
+		return bytes.TrimSuffix(bytes.TrimSuffix(l.line, []byte("\n")), []byte("\r")), nil
+	}
+}
+
+// Close releases the connection, the values that weren't read yet are discarded.
+func (l *JSONLines[T]) Close() error {
+	return l.resp.Body.Close()
+}
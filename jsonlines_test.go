@@ -0,0 +1,118 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamJSONLines(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get("Accept"); v != "application/x-ndjson" {
+			t.Errorf("expected %v, got %v", "application/x-ndjson", v)
+		}
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":1}` + "\r\n\n" + `{"id":` + "\n" + `{"id":"` + strings.Repeat("x", 8000) + `"}` + "\n" + `{"id":4}`))
+	}))
+	defer server.Close()
+
+	type record struct {
+		ID interface{} `json:"id"`
+	}
+	hc := newTestClient(t, server)
+	lines, err := StreamJSONLines[record](context.Background(), hc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer lines.Close()
+
+	var ids []interface{}
+	var lineErrs []int
+	for {
+		rec, err := lines.Next()
+		if err == io.EOF {
+			break
+		}
+		var lineErr *LineError
+		if errors.As(err, &lineErr) {
+			// skip the broken line
+			lineErrs = append(lineErrs, lineErr.Line)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids = append(ids, rec.ID)
+	}
+	if expected := []interface{}{float64(1), strings.Repeat("x", 8000), float64(4)}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v ids, got %v", len(expected), len(ids))
+	}
+	if expected := []int{3}; !reflect.DeepEqual(lineErrs, expected) {
+		t.Errorf("expected %v, got %v", expected, lineErrs)
+	}
+	if _, err := lines.Next(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+
+	_, err = StreamJSONLines[record](context.Background(), hc, WithPath("/missing"))
+	if StatusCodeOf(err) != http.StatusNotFound {
+		t.Errorf("expected a status error, got %v", err)
+	}
+}
+
+func TestStreamJSONLinesLongExport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unterminated" {
+			_, _ = w.Write([]byte(`{"id":"` + strings.Repeat("x", maxJSONLine)))
+			return
+		}
+		for i := 0; i < 5; i++ {
+			_, _ = w.Write([]byte(`{"id":1}` + "\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	// the export takes longer than the per try and the total timeout of the policy
+	hc := newPerTryTimeoutTestClient(t, server, 100*time.Millisecond)
+	lines, err := StreamJSONLines[map[string]int](context.Background(), hc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := 0
+	for ; ; n++ {
+		_, err := lines.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	_ = lines.Close()
+	if n != 5 {
+		t.Errorf("expected %v, got %v", 5, n)
+	}
+
+	lines, err = StreamJSONLines[map[string]int](context.Background(), hc, WithPath("/unterminated"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer lines.Close()
+	_, err = lines.Next()
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected %v, got %v", bufio.ErrTooLong, err)
+	}
+}
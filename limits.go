@@ -0,0 +1,127 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// WithMaxResponseBytes fails the request with an error matching ErrResponseTooLarge when the response body is
+// larger than n bytes, it overrides the limit of the static config.
+func WithMaxResponseBytes(n int64) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if n <= 0 {
+			return c, errors.New("max response bytes must be positive")
+		}
+		c.MaxResponseBytes = n
+		return c, nil
+	}
+}
+
+// WithStaticMaxResponseBytes limits the response body of all the requests that don't set their own limit.
+func WithStaticMaxResponseBytes(n int64) StaticRequestOption {
+	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
+		if n <= 0 {
+			return c, errors.New("max response bytes must be positive")
+		}
+		c.MaxResponseBytes = n
+		return c, nil
+	}
+}
+
+// WithReadIdleTimeout fails the request with ErrReadIdleTimeout when a single read of the response body blocks
+// for longer than d, it guards against upstreams that drip the body. it overrides the timeout of the static config.
+func WithReadIdleTimeout(d time.Duration) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if d <= 0 {
+			return c, errors.New("read idle timeout must be positive")
+		}
+		c.ReadIdleTimeout = d
+		return c, nil
+	}
+}
+
+// WithStaticReadIdleTimeout sets the read idle timeout of all the requests that don't set their own.
+func WithStaticReadIdleTimeout(d time.Duration) StaticRequestOption {
+	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
+		if d <= 0 {
+			return c, errors.New("read idle timeout must be positive")
+		}
+		c.ReadIdleTimeout = d
+		return c, nil
+	}
+}
+
+func maxResponseBytes(static StaticRequestConfig, rrc RuntimeRequestConfig) int64 {
+	if rrc.MaxResponseBytes > 0 {
+		return rrc.MaxResponseBytes
+	}
+	return static.MaxResponseBytes
+}
+
+func readIdleTimeout(static StaticRequestConfig, rrc RuntimeRequestConfig) time.Duration {
+	if rrc.ReadIdleTimeout > 0 {
+		return rrc.ReadIdleTimeout
+	}
+	return static.ReadIdleTimeout
+}
+
+// limitedBody fails the read that goes past limit, unlike io.LimitReader which silently truncates
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.read > b.limit {
+		return 0, &ResponseTooLargeError{Limit: b.limit, ContentLength: -1}
+	}
+	// one byte more than the limit is enough to tell that the body is too large
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n - int(b.read-b.limit), &ResponseTooLargeError{Limit: b.limit, ContentLength: -1}
+	}
+	return n, err
+}
+
+// idleTimeoutBody cancels the request when a single read blocks for longer than timeout
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	fired   int32
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel func()) *idleTimeoutBody {
+	b := &idleTimeoutBody{ReadCloser: body, timeout: timeout}
+	b.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&b.fired, 1)
+		cancel()
+	})
+	// the timer runs only while a read is in progress
+	b.timer.Stop()
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&b.fired) == 1 {
+		return 0, ErrReadIdleTimeout
+	}
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	if !b.timer.Stop() && atomic.LoadInt32(&b.fired) == 1 && err != nil && err != io.EOF {
+		err = ErrReadIdleTimeout
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,99 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := strings.Repeat("x", 100)
+		if r.URL.Path == "/chunked" {
+			// flushing before writing everything makes the response chunked, without a content length
+			_, _ = w.Write([]byte(body[:10]))
+			w.(http.Flusher).Flush()
+		} else {
+			body = body[:10] + body
+		}
+		_, _ = w.Write([]byte(body[10:]))
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions:        []ClientOption{WithGoStdClient(server.Client())},
+		StaticRequestOptions: []StaticRequestOption{WithURL(server.URL), WithStaticMaxResponseBytes(50)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		path          string
+		opts          []RuntimeRequestOption
+		contentLength int64
+		tooLarge      bool
+	}{
+		{path: "/", contentLength: 100, tooLarge: true},
+		{path: "/chunked", contentLength: -1, tooLarge: true},
+		{path: "/", opts: []RuntimeRequestOption{WithMaxResponseBytes(100)}},
+		{path: "/chunked", opts: []RuntimeRequestOption{WithMaxResponseBytes(100)}},
+		{path: "/chunked", opts: []RuntimeRequestOption{WithMaxResponseBytes(99)}, contentLength: -1, tooLarge: true},
+	}
+	for idx, testCase := range testCases {
+		buf := &bytes.Buffer{}
+		err := hc.Send(context.Background(), append(testCase.opts, WithPath(testCase.path), WithResponseBody(buf))...)
+		if !testCase.tooLarge {
+			if err != nil {
+				t.Errorf("test case %v: unexpected error: %v", idx, err)
+			} else if buf.Len() != 100 {
+				t.Errorf("test case %v: expected %v, got %v", idx, 100, buf.Len())
+			}
+			continue
+		}
+		if !errors.Is(err, ErrResponseTooLarge) {
+			t.Errorf("test case %v: expected %v, got %v", idx, ErrResponseTooLarge, err)
+			continue
+		}
+		var tooLargeErr *ResponseTooLargeError
+		if errors.As(err, &tooLargeErr); tooLargeErr.ContentLength != testCase.contentLength {
+			t.Errorf("test case %v: expected %v, got %v", idx, testCase.contentLength, tooLargeErr.ContentLength)
+		}
+		if int64(buf.Len()) > tooLargeErr.Limit {
+			t.Errorf("test case %v: expected at most %v bytes, got %v", idx, tooLargeErr.Limit, buf.Len())
+		}
+	}
+}
+
+func TestReadIdleTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("drip"))
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/stall" {
+			<-release
+		}
+		_, _ = w.Write([]byte("drop"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	hc := newTestClient(t, server, WithReadIdleTimeout(50*time.Millisecond))
+	buf := &bytes.Buffer{}
+	if err := hc.Send(context.Background(), WithResponseBody(buf)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != "dripdrop" {
+		t.Errorf("expected %v, got %v", "dripdrop", buf.String())
+	}
+
+	err := hc.Send(context.Background(), WithPath("/stall"), WithResponseBody(&bytes.Buffer{}))
+	if !errors.Is(err, ErrReadIdleTimeout) || !IsTimeout(err) {
+		t.Errorf("expected %v, got %v", ErrReadIdleTimeout, err)
+	}
+}
@@ -0,0 +1,245 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogLevel is the severity of a log entry
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota - 1
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// Field is a key value pair of a structured log entry
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger is the logging backend of the logging middleware, see NewSlogLogger for a log/slog adapter.
+type Logger interface {
+	Log(ctx context.Context, level LogLevel, msg string, fields ...Field)
+}
+
+// LoggingConfig configures the logging middleware. Authorization, Proxy-Authorization, Cookie and Set-Cookie
+// headers as well as the user info of the url are always redacted.
+type LoggingConfig struct {
+	// SensitiveHeaders and SensitiveQueryParams are redacted in addition to the default ones
+	SensitiveHeaders     []string
+	SensitiveQueryParams []string
+	// SampleEvery logs one of every n requests, requests that fail or get a 5xx are always logged. 0 logs all
+	SampleEvery int
+	// MaxBodyBytes logs up to this many bytes of the request and response bodies, 0 disables body logging.
+	// the request body is logged as the transport reads it, so streamed bodies are never read twice.
+	MaxBodyBytes int
+}
+
+const redacted = "redacted"
+
+var defaultSensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// NewLoggingMiddleware returns a middleware that logs every attempt of a request with its method, redacted url,
+// attempt number, status, latency, bytes sent and received, and the upstream service time reported by envoy.
+// an attempt that gets a response is logged when its body is closed, so the latency and the bytes received
+// cover the body.
+func NewLoggingMiddleware(logger Logger, cfg LoggingConfig) Middleware {
+	sensitiveHeaders := make(map[string]bool)
+	for _, h := range append(defaultSensitiveHeaders[:len(defaultSensitiveHeaders):len(defaultSensitiveHeaders)], cfg.SensitiveHeaders...) {
+		sensitiveHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+	sensitiveQueryParams := make(map[string]bool)
+	for _, p := range cfg.SensitiveQueryParams {
+		sensitiveQueryParams[p] = true
+	}
+	var count uint64
+
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			sampled := cfg.SampleEvery <= 1 || atomic.AddUint64(&count, 1)%uint64(cfg.SampleEvery) == 1
+			fields := []Field{
+				{Key: "method", Value: req.Method},
+				{Key: "url", Value: redactRequestURL(req.URL, sensitiveQueryParams)},
+				{Key: "attempt", Value: AttemptFromContext(req.Context())},
+				{Key: "request_headers", Value: redactHeaders(req.Header, sensitiveHeaders)},
+			}
+			var requestBody *snippetBody
+			if req.Body != nil && req.Body != http.NoBody {
+				// the request body can be a stream or a pipe that can't be read twice, so the bytes sent and the
+				// snippet are taken from what the transport reads. the content length is -1 for streamed bodies,
+				// and GetBody must not be used here, it rewinds the shared reader.
+				requestBody = &snippetBody{ReadCloser: req.Body}
+				if sampled {
+					requestBody.max = cfg.MaxBodyBytes
+				}
+				req = req.Clone(req.Context())
+				req.Body = requestBody
+			}
+			bytesOut := func() Field {
+				if requestBody == nil {
+					return Field{Key: "bytes_out", Value: int64(0)}
+				}
+				return Field{Key: "bytes_out", Value: requestBody.count()}
+			}
+
+			start := time.Now()
+			resp, err := next(req)
+			if requestBody != nil && requestBody.max > 0 {
+				fields = append(fields, Field{Key: "request_body", Value: requestBody.String()})
+			}
+			if err != nil {
+				fields = append(fields, bytesOut(), Field{Key: "latency", Value: time.Since(start)}, Field{Key: "error", Value: redactError(err, sensitiveQueryParams)})
+				logger.Log(req.Context(), LogLevelError, "http request failed", fields...)
+				return resp, err
+			}
+			if !sampled && resp.StatusCode < 500 {
+				return resp, nil
+			}
+
+			fields = append(fields,
+				Field{Key: "status", Value: resp.StatusCode},
+				Field{Key: "response_headers", Value: redactHeaders(resp.Header, sensitiveHeaders)},
+			)
+			if v := resp.Header.Get("x-envoy-upstream-service-time"); v != "" {
+				fields = append(fields, Field{Key: "upstream_service_time", Value: v})
+			}
+			level := LogLevelInfo
+			if resp.StatusCode >= 500 {
+				level = LogLevelWarn
+			}
+			body := &loggingBody{ReadCloser: resp.Body}
+			if sampled && cfg.MaxBodyBytes > 0 {
+				body.snippet = &snippetBody{max: cfg.MaxBodyBytes}
+			}
+			body.log = func() {
+				// the transport is done with the request body by the time the response body is closed
+				fields = append(fields, bytesOut(), Field{Key: "latency", Value: time.Since(start)}, Field{Key: "bytes_in", Value: body.n})
+				if body.snippet != nil {
+					fields = append(fields, Field{Key: "response_body", Value: body.snippet.String()})
+				}
+				logger.Log(req.Context(), level, "http request", fields...)
+			}
+			resp.Body = body
+			return resp, nil
+		}
+	}
+}
+
+// loggingBody counts the bytes read and logs the request once on close
+type loggingBody struct {
+	io.ReadCloser
+	n       int64
+	snippet *snippetBody
+	once    sync.Once
+	log     func()
+}
+
+func (b *loggingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if b.snippet != nil {
+		b.snippet.record(p[:n])
+	}
+	return n, err
+}
+
+func (b *loggingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.log)
+	return err
+}
+
+// snippetBody counts the bytes read from the body and records up to max of them. the transport reads the
+// request body from its own goroutine, so the snippet and the count are guarded.
+type snippetBody struct {
+	io.ReadCloser
+	max int
+	mu  sync.Mutex
+	buf bytes.Buffer
+	n   int64
+}
+
+func (b *snippetBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.record(p[:n])
+	return n, err
+}
+
+func (b *snippetBody) record(p []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.n += int64(len(p))
+	if rest := b.max - b.buf.Len(); rest > 0 {
+		if rest > len(p) {
+			rest = len(p)
+		}
+		b.buf.Write(p[:rest])
+	}
+}
+
+func (b *snippetBody) count() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.n
+}
+
+func (b *snippetBody) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// redactError returns the message of err with the url of the go std client's *url.Error redacted, the url
+// is the full request url including the sensitive query params.
+func redactError(err error, sensitiveQueryParams map[string]bool) string {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err.Error()
+	}
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return urlErr.Err.Error()
+	}
+	redactedErr := *urlErr
+	redactedErr.URL = redactRequestURL(u, sensitiveQueryParams)
+	// if the url error is wrapped, only the url error is logged, the wrapping messages can't be rebuilt
+	return redactedErr.Error()
+}
+
+// redactRequestURL returns the url without the user info and with the sensitive query params redacted
+func redactRequestURL(u *url.URL, sensitiveQueryParams map[string]bool) string {
+	redactedURL := *u
+	if len(sensitiveQueryParams) > 0 && redactedURL.RawQuery != "" {
+		query := redactedURL.Query()
+		for param := range query {
+			if sensitiveQueryParams[param] {
+				query[param] = []string{redacted}
+			}
+		}
+		redactedURL.RawQuery = query.Encode()
+	}
+	return redactURL(redactedURL)
+}
+
+func redactHeaders(headers http.Header, sensitive map[string]bool) http.Header {
+	out := make(http.Header, len(headers))
+	for k, vs := range headers {
+		if sensitive[http.CanonicalHeaderKey(k)] {
+			out[k] = []string{redacted}
+			continue
+		}
+		out[k] = append([]string(nil), vs...)
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+//go:build go1.21
+
+package httpclient
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger adapts a log/slog logger to the Logger of the logging middleware.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+	l.logger.LogAttrs(ctx, slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
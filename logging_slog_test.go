@@ -0,0 +1,20 @@
+//go:build go1.21
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(buf, nil)))
+	logger.Log(context.Background(), LogLevelWarn, "http request", Field{Key: "status", Value: 503})
+	if out := buf.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, "status=503") {
+		t.Errorf("expected a warn entry with the status, got %v", out)
+	}
+}
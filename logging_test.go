@@ -0,0 +1,188 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type logEntry struct {
+	level  LogLevel
+	msg    string
+	fields map[string]interface{}
+}
+
+type fakeLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *fakeLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {
+	entry := logEntry{level: level, msg: msg, fields: make(map[string]interface{})}
+	for _, field := range fields {
+		entry.fields[field.Key] = field.Value
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Envoy-Upstream-Service-Time", "12")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte("response body"))
+	}))
+	defer server.Close()
+
+	logger := &fakeLogger{}
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{
+			WithGoStdClient(server.Client()),
+			WithMiddlewares(NewLoggingMiddleware(logger, LoggingConfig{
+				SensitiveHeaders:     []string{"x-api-key"},
+				SensitiveQueryParams: []string{"token"},
+				SampleEvery:          2,
+				MaxBodyBytes:         8,
+			})),
+		},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			func(c StaticRequestConfig) (StaticRequestConfig, error) {
+				c.User, c.Password = "user", "secret"
+				return c, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	send := func(path string) {
+		buf := &bytes.Buffer{}
+		_ = hc.Send(context.Background(), WithMethod(MethodPost), WithPath(path), WithQueryParam("token", "secret"),
+			WithRuntimeHeader("X-Api-Key", "secret"), WithRequestJSON(map[string]string{"a": "secret"}), WithResponseBody(buf))
+	}
+	// the second request is not sampled, the third one fails and is logged regardless
+	send("/ok")
+	send("/ok")
+	send("/fail")
+
+	if len(logger.entries) != 2 {
+		t.Fatalf("expected %v, got %v", 2, len(logger.entries))
+	}
+	entry := logger.entries[0]
+	if entry.level != LogLevelInfo || logger.entries[1].level != LogLevelWarn {
+		t.Errorf("expected %v and %v, got %v and %v", LogLevelInfo, LogLevelWarn, entry.level, logger.entries[1].level)
+	}
+	for key, expected := range map[string]interface{}{
+		"method":                http.MethodPost,
+		"attempt":               1,
+		"status":                http.StatusOK,
+		"bytes_out":             int64(len(`{"a":"secret"}`)),
+		"bytes_in":              int64(len("response body")),
+		"upstream_service_time": "12",
+		"request_body":          `{"a":"se`,
+		"response_body":         "response",
+	} {
+		if entry.fields[key] != expected {
+			t.Errorf("%v: expected %v, got %v", key, expected, entry.fields[key])
+		}
+	}
+	if u := entry.fields["url"].(string); strings.Contains(u, "secret") || !strings.Contains(u, "token=redacted") {
+		t.Errorf("expected a redacted url, got %v", u)
+	}
+	for _, key := range []string{"request_headers", "response_headers"} {
+		for name, values := range entry.fields[key].(http.Header) {
+			if strings.Contains(strings.Join(values, ","), "secret") {
+				t.Errorf("%v: expected %v to be redacted, got %v", key, name, values)
+			}
+		}
+	}
+}
+
+func TestLoggingMiddlewareStreamedBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	logger := &fakeLogger{}
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{
+			WithGoStdClient(server.Client()),
+			WithMiddlewares(NewLoggingMiddleware(logger, LoggingConfig{MaxBodyBytes: 4})),
+		},
+		StaticRequestOptions: []StaticRequestOption{WithURL(server.URL)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		body     RuntimeRequestOption
+		expected string
+	}{
+		"seekable reader": {body: WithRequestBodyReader(strings.NewReader("0123456789"), 10), expected: "0123456789"},
+		"body func": {body: WithRequestBodyFunc(func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("0123456789")), nil
+		}), expected: "0123456789"},
+		"multipart": {body: WithMultipart(FieldPart("title", "report")), expected: "title"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := hc.Send(context.Background(), WithMethod(MethodPost), tc.body, WithResponseBody(buf))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(buf.String(), tc.expected) {
+				t.Errorf("expected the body to contain %v, got %v", tc.expected, buf.String())
+			}
+			logger.mu.Lock()
+			defer logger.mu.Unlock()
+			entry := logger.entries[len(logger.entries)-1]
+			if snippet := entry.fields["request_body"].(string); len(snippet) != 4 || !strings.HasPrefix(buf.String(), snippet) {
+				t.Errorf("expected the first 4 bytes of %v, got %v", buf.String(), snippet)
+			}
+			// the streamed bodies have no content length, the bytes sent are counted
+			if n := entry.fields["bytes_out"]; n != int64(buf.Len()) {
+				t.Errorf("expected %v, got %v", buf.Len(), n)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddlewareRedactsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serverURL := server.URL
+	server.Close()
+
+	logger := &fakeLogger{}
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{
+			WithMiddlewares(NewLoggingMiddleware(logger, LoggingConfig{SensitiveQueryParams: []string{"token"}})),
+		},
+		StaticRequestOptions: []StaticRequestOption{WithURL(serverURL)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := hc.Send(context.Background(), WithQueryParam("token", "secret"), WithResponseBody(io.Discard)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(logger.entries) != 1 {
+		t.Fatalf("expected %v, got %v", 1, len(logger.entries))
+	}
+	if msg := logger.entries[0].fields["error"].(string); strings.Contains(msg, "secret") || !strings.Contains(msg, "token=redacted") {
+		t.Errorf("expected a redacted error, got %v", msg)
+	}
+}
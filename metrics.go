@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricLabels are the labels of the client metrics. StatusClass is 2xx, 4xx etc, or "error" when no response
+// was received, it is empty for the in-flight gauge.
+type MetricLabels struct {
+	Host        string
+	Method      string
+	Route       string
+	StatusClass string
+}
+
+// MetricsRecorder receives the measurements of the metrics middleware, see InMemoryRecorder for an implementation
+// that can be scraped by prometheus.
+type MetricsRecorder interface {
+	// AddInFlight is called with 1 when an attempt starts and with -1 when it is done
+	AddInFlight(labels MetricLabels, delta int)
+	// ObserveRequest is called once per attempt, latency and responseBytes cover reading the response body
+	ObserveRequest(labels MetricLabels, latency time.Duration, responseBytes int64)
+	// IncRetries is called for every attempt after the first one
+	IncRetries(labels MetricLabels)
+}
+
+type routeTemplateContextKey struct{}
+
+// WithRouteTemplate sets the route label of the request metrics, e.g. /users/{id}, so that the metrics aren't
+// labelled by the actual path.
+func WithRouteTemplate(route string) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if route == "" {
+			return c, errors.New("route template is empty")
+		}
+		c.RouteTemplate = route
+		return c, nil
+	}
+}
+
+// RouteTemplateFromContext returns the route template set with WithRouteTemplate, it is meant to be used by middlewares.
+func RouteTemplateFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeTemplateContextKey{}).(string)
+	return route
+}
+
+// NewMetricsMiddleware returns a middleware that records the metrics of every attempt of a request. an attempt
+// that gets a response is observed when its body is closed.
+func NewMetricsMiddleware(recorder MetricsRecorder) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			labels := MetricLabels{
+				Host:   req.URL.Host,
+				Method: req.Method,
+				Route:  RouteTemplateFromContext(req.Context()),
+			}
+			if AttemptFromContext(req.Context()) > 1 {
+				recorder.IncRetries(labels)
+			}
+			recorder.AddInFlight(labels, 1)
+
+			start := time.Now()
+			resp, err := next(req)
+			if err != nil {
+				recorder.AddInFlight(labels, -1)
+				labels.StatusClass = "error"
+				recorder.ObserveRequest(labels, time.Since(start), 0)
+				return resp, err
+			}
+			body := &metricsBody{ReadCloser: resp.Body}
+			body.done = func() {
+				recorder.AddInFlight(labels, -1)
+				observed := labels
+				observed.StatusClass = statusClass(resp.StatusCode)
+				recorder.ObserveRequest(observed, time.Since(start), body.n)
+			}
+			resp.Body = body
+			return resp, nil
+		}
+	}
+}
+
+// metricsBody counts the bytes read and observes the attempt once on close
+type metricsBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func()
+}
+
+func (b *metricsBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *metricsBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
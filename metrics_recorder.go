@@ -0,0 +1,198 @@
+package httpclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultLatencyBuckets are the upper bounds of the latency histogram in seconds
+	DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// DefaultSizeBuckets are the upper bounds of the response size histogram in bytes
+	DefaultSizeBuckets = []float64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+)
+
+// InMemoryRecorder is a MetricsRecorder that keeps the metrics in memory, it is handy in tests and it can be
+// scraped by prometheus through WritePrometheus or as a http.Handler.
+type InMemoryRecorder struct {
+	mu             sync.Mutex
+	latencyBuckets []float64
+	sizeBuckets    []float64
+	requests       map[MetricLabels]uint64
+	latencies      map[MetricLabels]*histogram
+	sizes          map[MetricLabels]*histogram
+	inFlight       map[MetricLabels]int64
+	retries        map[MetricLabels]uint64
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	for i, upper := range buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// NewInMemoryRecorder returns a recorder with the default buckets.
+func NewInMemoryRecorder() *InMemoryRecorder {
+	return NewInMemoryRecorderWithBuckets(DefaultLatencyBuckets, DefaultSizeBuckets)
+}
+
+// NewInMemoryRecorderWithBuckets returns a recorder with the given histogram buckets, they must be sorted.
+func NewInMemoryRecorderWithBuckets(latencyBuckets, sizeBuckets []float64) *InMemoryRecorder {
+	return &InMemoryRecorder{
+		latencyBuckets: append([]float64(nil), latencyBuckets...),
+		sizeBuckets:    append([]float64(nil), sizeBuckets...),
+		requests:       make(map[MetricLabels]uint64),
+		latencies:      make(map[MetricLabels]*histogram),
+		sizes:          make(map[MetricLabels]*histogram),
+		inFlight:       make(map[MetricLabels]int64),
+		retries:        make(map[MetricLabels]uint64),
+	}
+}
+
+func (r *InMemoryRecorder) AddInFlight(labels MetricLabels, delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight[labels] += int64(delta)
+}
+
+func (r *InMemoryRecorder) ObserveRequest(labels MetricLabels, latency time.Duration, responseBytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests[labels]++
+	if r.latencies[labels] == nil {
+		r.latencies[labels] = &histogram{counts: make([]uint64, len(r.latencyBuckets))}
+		r.sizes[labels] = &histogram{counts: make([]uint64, len(r.sizeBuckets))}
+	}
+	r.latencies[labels].observe(r.latencyBuckets, latency.Seconds())
+	r.sizes[labels].observe(r.sizeBuckets, float64(responseBytes))
+}
+
+func (r *InMemoryRecorder) IncRetries(labels MetricLabels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries[labels]++
+}
+
+// Requests returns the number of requests observed with the labels
+func (r *InMemoryRecorder) Requests(labels MetricLabels) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[labels]
+}
+
+// InFlight returns the number of requests in flight with the labels, StatusClass is ignored
+func (r *InMemoryRecorder) InFlight(labels MetricLabels) int64 {
+	labels.StatusClass = ""
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.inFlight[labels]
+}
+
+// Retries returns the number of retries with the labels, StatusClass is ignored
+func (r *InMemoryRecorder) Retries(labels MetricLabels) uint64 {
+	labels.StatusClass = ""
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.retries[labels]
+}
+
+// ResponseBytes returns the total size of the responses observed with the labels
+func (r *InMemoryRecorder) ResponseBytes(labels MetricLabels) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h := r.sizes[labels]; h != nil {
+		return int64(h.sum)
+	}
+	return 0
+}
+
+// WritePrometheus writes the metrics in the prometheus text exposition format.
+func (r *InMemoryRecorder) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	writeHeader(bw, "http_client_requests_total", "counter", "Number of request attempts.")
+	for _, labels := range sortedLabels(r.requests) {
+		fmt.Fprintf(bw, "http_client_requests_total{%s} %d\n", formatLabels(labels), r.requests[labels])
+	}
+	writeHeader(bw, "http_client_request_duration_seconds", "histogram", "Latency of request attempts, including reading the body.")
+	for _, labels := range sortedLabels(r.latencies) {
+		writeHistogram(bw, "http_client_request_duration_seconds", labels, r.latencyBuckets, r.latencies[labels])
+	}
+	writeHeader(bw, "http_client_response_size_bytes", "histogram", "Size of the response bodies read.")
+	for _, labels := range sortedLabels(r.sizes) {
+		writeHistogram(bw, "http_client_response_size_bytes", labels, r.sizeBuckets, r.sizes[labels])
+	}
+	writeHeader(bw, "http_client_in_flight_requests", "gauge", "Number of request attempts in flight.")
+	for _, labels := range sortedLabels(r.inFlight) {
+		fmt.Fprintf(bw, "http_client_in_flight_requests{%s} %d\n", formatLabels(labels), r.inFlight[labels])
+	}
+	writeHeader(bw, "http_client_retries_total", "counter", "Number of retried request attempts.")
+	for _, labels := range sortedLabels(r.retries) {
+		fmt.Fprintf(bw, "http_client_retries_total{%s} %d\n", formatLabels(labels), r.retries[labels])
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics for prometheus to scrape
+func (r *InMemoryRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WritePrometheus(w)
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeHistogram(w io.Writer, name string, labels MetricLabels, buckets []float64, h *histogram) {
+	formatted := formatLabels(labels)
+	for i, upper := range buckets {
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, formatted, strconv.FormatFloat(upper, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, formatted, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, formatted, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, formatted, h.count)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels MetricLabels) string {
+	pairs := []string{
+		`host="` + labelEscaper.Replace(labels.Host) + `"`,
+		`method="` + labelEscaper.Replace(labels.Method) + `"`,
+		`route="` + labelEscaper.Replace(labels.Route) + `"`,
+	}
+	if labels.StatusClass != "" {
+		pairs = append(pairs, `status_class="`+labelEscaper.Replace(labels.StatusClass)+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func sortedLabels[V any](m map[MetricLabels]V) []MetricLabels {
+	labels := make([]MetricLabels, 0, len(m))
+	for l := range m {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return formatLabels(labels[i]) < formatLabels(labels[j])
+	})
+	return labels
+}
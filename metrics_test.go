@@ -0,0 +1,87 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	recorder := NewInMemoryRecorder()
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{WithGoStdClient(server.Client()), WithMiddlewares(NewMetricsMiddleware(recorder))},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			WithEnvoyRetryPolicy(EnvoyRetryPolicy{
+				MaxRetries:    2,
+				TotalTimeout:  5 * time.Second,
+				PerTryTimeout: time.Second,
+				RetryOn:       []RetryOnCode{RetryOn5xx},
+				BaseInterval:  time.Millisecond,
+			}),
+			WithRetryMode(RetryModeInProcess),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = hc.Send(context.Background(), WithPath("/users/123"), WithRouteTemplate("/users/{id}"), WithResponseBody(io.Discard))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	u, _ := url.Parse(server.URL)
+	labels := MetricLabels{Host: u.Host, Method: http.MethodGet, Route: "/users/{id}"}
+	testCases := []struct {
+		name     string
+		got      interface{}
+		expected interface{}
+	}{
+		{name: "5xx requests", got: recorder.Requests(MetricLabels{Host: u.Host, Method: http.MethodGet, Route: "/users/{id}", StatusClass: "5xx"}), expected: uint64(2)},
+		{name: "2xx requests", got: recorder.Requests(MetricLabels{Host: u.Host, Method: http.MethodGet, Route: "/users/{id}", StatusClass: "2xx"}), expected: uint64(1)},
+		{name: "retries", got: recorder.Retries(labels), expected: uint64(2)},
+		{name: "in flight", got: recorder.InFlight(labels), expected: int64(0)},
+		{name: "response bytes", got: recorder.ResponseBytes(MetricLabels{Host: u.Host, Method: http.MethodGet, Route: "/users/{id}", StatusClass: "2xx"}), expected: int64(5)},
+	}
+	for _, testCase := range testCases {
+		if testCase.got != testCase.expected {
+			t.Errorf("%v: expected %v, got %v", testCase.name, testCase.expected, testCase.got)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := recorder.WritePrometheus(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	labelSet := `host="` + u.Host + `",method="GET",route="/users/{id}"`
+	for _, line := range []string{
+		"# TYPE http_client_requests_total counter",
+		`http_client_requests_total{` + labelSet + `,status_class="2xx"} 1`,
+		`http_client_requests_total{` + labelSet + `,status_class="5xx"} 2`,
+		`http_client_request_duration_seconds_count{` + labelSet + `,status_class="5xx"} 2`,
+		`http_client_response_size_bytes_bucket{` + labelSet + `,status_class="2xx",le="256"} 1`,
+		`http_client_in_flight_requests{` + labelSet + `} 0`,
+		`http_client_retries_total{` + labelSet + `} 2`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("expected %v in the exposition, got\n%v", line, buf.String())
+		}
+	}
+}
@@ -1 +1,62 @@
 package httpclient
+
+import (
+	"errors"
+	"net/http"
+)
+
+// RoundTripFunc sends a single attempt of a request. the innermost RoundTripFunc of the chain is the
+// go std client, so every attempt (including retries) flows through all the middlewares.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip makes RoundTripFunc usable as a http.RoundTripper
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a RoundTripFunc, useful for logging, metrics, auth, tracing etc.
+// a middleware is expected to call next at most once per invocation and to return the response
+// of next as is or with a wrapped body, it shouldn't read the body on behalf of the caller.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// chainMiddlewares wraps final with the given middlewares. the first middleware is the outermost,
+// i.e. it sees the request first and the response last.
+func chainMiddlewares(final RoundTripFunc, mws ...Middleware) RoundTripFunc {
+	next := final
+	for i := len(mws) - 1; i >= 0; i-- {
+		next = mws[i](next)
+	}
+	return next
+}
+
+func validateMiddlewares(mws []Middleware) error {
+	for _, mw := range mws {
+		if mw == nil {
+			return errors.New("nil middleware passed")
+		}
+	}
+	return nil
+}
+
+// WithMiddlewares appends middlewares that apply to every request sent through the client.
+// client middlewares are always outside of the per request middlewares given with WithRequestMiddlewares.
+func WithMiddlewares(mws ...Middleware) ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if err := validateMiddlewares(mws); err != nil {
+			return c, err
+		}
+		c.Middlewares = append(c.Middlewares[:len(c.Middlewares):len(c.Middlewares)], mws...)
+		return c, nil
+	}
+}
+
+// WithRequestMiddlewares appends middlewares that apply only to this request.
+func WithRequestMiddlewares(mws ...Middleware) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if err := validateMiddlewares(mws); err != nil {
+			return c, err
+		}
+		c.Middlewares = append(c.Middlewares[:len(c.Middlewares):len(c.Middlewares)], mws...)
+		return c, nil
+	}
+}
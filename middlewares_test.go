@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMiddlewaresOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get("X-Auth"); v != "token" {
+			t.Errorf("expected %v, got %v", "token", v)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+":before")
+				resp, err := next(req)
+				calls = append(calls, name+":after")
+				return resp, err
+			}
+		}
+	}
+	auth := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Auth", "token")
+			return next(req)
+		}
+	}
+
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{
+			WithGoStdClient(server.Client()),
+			WithMiddlewares(record("client1"), record("client2")),
+			WithMiddlewares(auth),
+		},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = hc.Send(context.Background(), WithResponseBody(buf), WithRequestMiddlewares(record("request")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"client1:before", "client2:before", "request:before",
+		"request:after", "client2:after", "client1:after",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected %v, got %v", expected, calls)
+	}
+
+	// nil middlewares are rejected
+	if _, err := WithMiddlewares(nil)(ClientConfig{}); err == nil {
+		t.Errorf("expected error to be set as middleware is nil")
+	}
+}
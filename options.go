@@ -1,13 +1,15 @@
 package httpclient
 
 import (
-	"encoding/json"
+	"crypto/tls"
+	"crypto/x509"
 	"errors" // todo: replace with self errors library that adds stack trace and passing context
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -33,6 +35,154 @@ func WithInsecureSkipVerify(val bool) ClientOption {
 	}
 }
 
+// WithDefaultTransport sets the transport that the client uses when none is given, so that it can be tuned
+// further with the transport options below.
+func WithDefaultTransport() ClientOption {
+	return WithTransport(buildDefaultTransport())
+}
+
+// ensureTransport sets the default transport unless the earlier options already set a client or a transport
+func ensureTransport() ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if c.Client != nil {
+			return c, errors.New("transport options cannot be applied on a custom go std client")
+		}
+		if c.Transport == nil {
+			c.Transport = buildDefaultTransport()
+		}
+		return c, nil
+	}
+}
+
+func WithTransport(transport *http.Transport) ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if transport == nil {
+			return c, errors.New("transport is nil")
+		}
+		c.Transport = transport
+		return c, nil
+	}
+}
+
+func WithClientTimeout(timeout time.Duration) ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if timeout < 0 {
+			return c, fmt.Errorf("client timeout(%d) cannot be negative", timeout)
+		}
+		c.ClientTimeout = timeout
+		return c, nil
+	}
+}
+
+// WithConnectionPool sets the connection pool sizes of the transport, zero values leave the existing value as is.
+func WithConnectionPool(maxIdleConns, maxIdleConnsPerHost, maxConnsPerHost int, idleConnTimeout time.Duration) ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if c.Transport == nil {
+			return c, errors.New("transport is not set")
+		}
+		if maxIdleConns < 0 || maxIdleConnsPerHost < 0 || maxConnsPerHost < 0 || idleConnTimeout < 0 {
+			return c, errors.New("connection pool sizes and idle timeout cannot be negative")
+		}
+		if maxIdleConns > 0 {
+			c.Transport.MaxIdleConns = maxIdleConns
+		}
+		if maxIdleConnsPerHost > 0 {
+			c.Transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		}
+		if maxConnsPerHost > 0 {
+			c.Transport.MaxConnsPerHost = maxConnsPerHost
+		}
+		if idleConnTimeout > 0 {
+			c.Transport.IdleConnTimeout = idleConnTimeout
+		}
+		return c, nil
+	}
+}
+
+func WithDialTimeout(timeout time.Duration) ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if c.Transport == nil {
+			return c, errors.New("transport is not set")
+		}
+		if timeout <= 0 {
+			return c, fmt.Errorf("dial timeout(%d) must be positive", timeout)
+		}
+		// clear the deprecated Dial set by the default transport, DialContext takes over
+		c.Transport.Dial = nil
+		c.Transport.DialContext = (&net.Dialer{Timeout: timeout}).DialContext
+		return c, nil
+	}
+}
+
+func WithTLSHandshakeTimeout(timeout time.Duration) ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if c.Transport == nil {
+			return c, errors.New("transport is not set")
+		}
+		if timeout <= 0 {
+			return c, fmt.Errorf("tls handshake timeout(%d) must be positive", timeout)
+		}
+		c.Transport.TLSHandshakeTimeout = timeout
+		return c, nil
+	}
+}
+
+// WithRootCAFile trusts the PEM encoded certificates in the file instead of the system roots.
+func WithRootCAFile(caFile string) ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if c.Transport == nil {
+			return c, errors.New("transport is not set")
+		}
+		if c.Transport.TLSClientConfig == nil {
+			return c, errors.New("tls client config is not set")
+		}
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return c, fmt.Errorf("reading ca file %s: %w", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return c, fmt.Errorf("no certificates found in ca file %s", caFile)
+		}
+		c.Transport.TLSClientConfig.RootCAs = pool
+		return c, nil
+	}
+}
+
+// WithClientCertificateFiles sets the certificate presented to the upstream for mutual tls.
+func WithClientCertificateFiles(certFile, keyFile string) ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if c.Transport == nil {
+			return c, errors.New("transport is not set")
+		}
+		if c.Transport.TLSClientConfig == nil {
+			return c, errors.New("tls client config is not set")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return c, fmt.Errorf("loading client certificate %s, %s: %w", certFile, keyFile, err)
+		}
+		c.Transport.TLSClientConfig.Certificates = append(c.Transport.TLSClientConfig.Certificates, cert)
+		return c, nil
+	}
+}
+
+func WithTLSServerName(serverName string) ClientOption {
+	return func(c ClientConfig) (ClientConfig, error) {
+		if c.Transport == nil {
+			return c, errors.New("transport is not set")
+		}
+		if c.Transport.TLSClientConfig == nil {
+			return c, errors.New("tls client config is not set")
+		}
+		if serverName == "" {
+			return c, errors.New("server name is empty")
+		}
+		c.Transport.TLSClientConfig.ServerName = serverName
+		return c, nil
+	}
+}
+
 // static request configuration options
 func WithScheme(scheme string) StaticRequestOption {
 	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
@@ -180,6 +330,16 @@ const (
 	retryOnRetriableHeaders     RetryOnCode = "retriable-headers"
 )
 
+func validateRetryOnCode(code RetryOnCode) error {
+	switch code {
+	case RetryOn5xx, RetryOnGatewayError, RetryOnReset, RetryOnConnectFailure, RetryOnEnvoyRatelimited,
+		RetryOnRetriable4xx, RetryOnRefusedStream, RetryOnHTTP3PostConnectFailure,
+		retryOnRetriableStatusCodes, retryOnRetriableHeaders:
+		return nil
+	}
+	return fmt.Errorf("unknown retry on code %q", code)
+}
+
 type EnvoyRetryPolicy struct {
 	MaxRetries           uint16
 	TotalTimeout         time.Duration
@@ -187,6 +347,23 @@ type EnvoyRetryPolicy struct {
 	RetryOn              []RetryOnCode
 	RetriableStatusCodes []uint16
 	RetriableHeaders     []string
+	// Backoff, BaseInterval and MaxInterval control the wait between retries, the zero values mirror envoy's
+	// defaults i.e. fully jittered exponential backoff with 25ms base interval and max interval of 10 times the base.
+	// they are only used by in process retries, envoy has no request header for them and reads the backoff
+	// from the retry_back_off of the route config.
+	Backoff      BackoffStrategy
+	BaseInterval time.Duration
+	MaxInterval  time.Duration
+	// CustomBackoff takes precedence over Backoff when set, it is only used by in process retries.
+	CustomBackoff BackoffFunc
+}
+
+func (rp EnvoyRetryPolicy) Clone() EnvoyRetryPolicy {
+	clone := rp
+	clone.RetryOn = append([]RetryOnCode(nil), rp.RetryOn...)
+	clone.RetriableStatusCodes = append([]uint16(nil), rp.RetriableStatusCodes...)
+	clone.RetriableHeaders = append([]string(nil), rp.RetriableHeaders...)
+	return clone
 }
 
 func DefaultEnvoyRetryPolicy() EnvoyRetryPolicy {
@@ -217,16 +394,24 @@ func WithDefaultEnvoyRetryPolicy() StaticRequestOption {
 // WithEnvoyRetryPolicy sets the retry policy for the request.
 // It validates the retry policy, and returns an error if the retry policy is invalid.
 // if max retries is set or non-zero then total timeout and per try timeout is compulsory.
+// by default the policy is only sent as x-envoy-* headers to the sidecar, use WithRetryMode to execute it in process.
 func WithEnvoyRetryPolicy(retryPolicy EnvoyRetryPolicy) StaticRequestOption {
+	retryPolicy = retryPolicy.Clone()
 	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
 		err := validateEnvoyRetryPolicy(retryPolicy)
 		if err != nil {
 			return c, err
 		}
 		c = c.Clone()
+		rp := retryPolicy.Clone()
+		c.RetryPolicy = &rp
 		if c.Headers == nil {
 			c.Headers = make(http.Header)
 		}
+		for _, header := range envoyRetryPolicyHeaders {
+			// the previous policy might have set headers that this one doesn't
+			c.Headers.Del(header)
+		}
 		c.Headers.Set("x-envoy-max-retries", strconv.FormatUint(uint64(retryPolicy.MaxRetries), 10))
 		if retryPolicy.TotalTimeout > 0 {
 			c.Headers.Set("x-envoy-upstream-rq-timeout-ms", strconv.FormatUint(uint64(retryPolicy.TotalTimeout/time.Millisecond), 10))
@@ -247,6 +432,17 @@ func WithEnvoyRetryPolicy(retryPolicy EnvoyRetryPolicy) StaticRequestOption {
 	}
 }
 
+// envoyRetryPolicyHeaders are the headers set by WithEnvoyRetryPolicy, they are not sent when the
+// policy is only executed in process.
+var envoyRetryPolicyHeaders = []string{
+	"x-envoy-max-retries",
+	"x-envoy-upstream-rq-timeout-ms",
+	"x-envoy-upstream-rq-per-try-timeout-ms",
+	"x-envoy-retriable-status-codes",
+	"x-envoy-retriable-headers",
+	"x-envoy-retry-on",
+}
+
 func validateEnvoyRetryPolicy(rp EnvoyRetryPolicy) error {
 	// check if max retries is set or non-zero then total timeout and per try timeout is compulsory.
 	if rp.MaxRetries > 0 && (rp.TotalTimeout == 0 || rp.PerTryTimeout == 0) {
@@ -271,6 +467,15 @@ func validateEnvoyRetryPolicy(rp EnvoyRetryPolicy) error {
 			return fmt.Errorf("empty header passed in RetriableHeaders")
 		}
 	}
+	if err := validateBackoffStrategy(rp.Backoff); err != nil {
+		return err
+	}
+	if rp.BaseInterval < 0 || rp.MaxInterval < 0 {
+		return fmt.Errorf("baseInterval(%d) and maxInterval(%d) cannot be negative", rp.BaseInterval, rp.MaxInterval)
+	}
+	if rp.MaxInterval > 0 && rp.MaxInterval < rp.BaseInterval {
+		return fmt.Errorf("maxInterval(%d) is less than baseInterval(%d)", rp.MaxInterval, rp.BaseInterval)
+	}
 
 	// todo: setup validation packs where users can opt into default validation pack or extend the default validation pack with their own custom validation packs
 	// if rp.MaxRetries > 10 {
@@ -287,6 +492,10 @@ func WithStaticHeaders(headers map[string]string) StaticRequestOption {
 		if err != nil {
 			return c, err
 		}
+		c = c.Clone()
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
 		for k, v := range headers {
 			c.Headers.Set(k, v)
 		}
@@ -299,11 +508,30 @@ func WithStaticHeader(key, value string) StaticRequestOption {
 		if key == "" || value == "" {
 			return c, errors.New("key or value is empty")
 		}
+		c = c.Clone()
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
 		c.Headers.Set(key, value)
 		return c, nil
 	}
 }
 
+// WithAddStaticHeader appends a value to the static header, useful for multi valued headers.
+func WithAddStaticHeader(key, value string) StaticRequestOption {
+	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
+		if key == "" || value == "" {
+			return c, errors.New("key or value is empty")
+		}
+		c = c.Clone()
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
+		c.Headers.Add(key, value)
+		return c, nil
+	}
+}
+
 func validateStaticHeaders(headers map[string]string) error {
 	if headers == nil {
 		return errors.New("headers is nil")
@@ -313,7 +541,7 @@ func validateStaticHeaders(headers map[string]string) error {
 
 // runtime request configuration options
 
-// useful for setting runtime headers that are request specific. (use proper tracing middlewares for setting these)
+// useful for setting runtime headers that are request specific. (use NewTracingMiddleware for the tracing headers)
 // only for exceptional cases where you want to set a header that couldn't be abstracted into a middleware use this
 func WithRuntimeHeaders(headers map[string]string) RuntimeRequestOption {
 	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
@@ -321,6 +549,9 @@ func WithRuntimeHeaders(headers map[string]string) RuntimeRequestOption {
 		if err != nil {
 			return c, err
 		}
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
 		for k, v := range headers {
 			c.Headers.Set(k, v)
 		}
@@ -328,16 +559,47 @@ func WithRuntimeHeaders(headers map[string]string) RuntimeRequestOption {
 	}
 }
 
+// WithRuntimeHeader replaces all the values of the header, including the ones inherited from the static
+// headers and the client's default runtime headers.
 func WithRuntimeHeader(key, value string) RuntimeRequestOption {
 	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
 		if key == "" || value == "" {
 			return c, errors.New("key or value is empty")
 		}
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
 		c.Headers.Set(key, value)
 		return c, nil
 	}
 }
 
+// WithAddRuntimeHeader appends a value to the header, keeping the values inherited from the static
+// headers and the client's default runtime headers.
+func WithAddRuntimeHeader(key, value string) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if key == "" || value == "" {
+			return c, errors.New("key or value is empty")
+		}
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
+		c.Headers.Add(key, value)
+		return c, nil
+	}
+}
+
+// WithoutHeader removes a header for this request, e.g. to drop a static header for a single call.
+func WithoutHeader(key string) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if key == "" {
+			return c, errors.New("key is empty")
+		}
+		c.Headers.Del(key)
+		return c, nil
+	}
+}
+
 func validateRuntimeHeaders(headers map[string]string) error {
 	if headers == nil {
 		return errors.New("headers is nil")
@@ -352,20 +614,9 @@ func WithGoStdClient(client *http.Client) ClientOption {
 	}
 }
 
+// WithRequestJSON sets the body to body encoded with the json codec of the client when the request is sent.
 func WithRequestJSON(body interface{}) RuntimeRequestOption {
-	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
-		// todo: allow custom json encoders in the static config
-		bts, err := json.Marshal(body)
-		if err != nil {
-			return c, err
-		}
-		c.Body = bts
-		if c.Headers == nil {
-			c.Headers = make(http.Header)
-		}
-		c.Headers.Set("Content-Type", "application/json")
-		return c, nil
-	}
+	return withRequestBody(body, "application/json")
 }
 
 func WithResponseJSON(response interface{}) RuntimeRequestOption {
@@ -382,6 +633,15 @@ func WithResponseBody(response io.Writer) RuntimeRequestOption {
 	}
 }
 
+// WithResponseHandler hands the response over to handler instead of reading it, for callers that want to
+// consume the raw stream. handler is called only when the status is expected, its error is returned by Send.
+func WithResponseHandler(handler func(*http.Response) error) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		c.ResponseHandler = handler
+		return c, nil
+	}
+}
+
 type HttpMethod string
 
 const (
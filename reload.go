@@ -0,0 +1,68 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Reload atomically replaces the static config, the default runtime config, the middlewares and the timeout
+// of the client with the ones built from co. the connection pool is kept, so co cannot set a client and its
+// transport settings are ignored, they only apply when the client is created. this way the same layers that
+// built the client (e.g. a FileConfig with transport settings) can be reloaded as is. in-flight requests
+// finish with the config they started with, new requests pick up the new one. co is the complete config and
+// not a diff on top of the current one, if it is invalid the current config stays in effect and the error is
+// returned.
+func (c *Client) Reload(co ConfigOptions) error {
+	cfg, err := NewConfig(co)
+	if err != nil {
+		return err
+	}
+	if cfg.ClientConfig.Client != nil {
+		return &ConfigError{Kind: ConfigKindClient, Index: -1, Err: errors.New("client cannot be reloaded")}
+	}
+
+	current := c.state.Load()
+	next := &clientState{
+		stdClient:            current.stdClient,
+		customStdClient:      current.customStdClient,
+		middlewares:          cfg.ClientConfig.Middlewares,
+		staticRequestConfig:  cfg.StaticRequestConfig,
+		runtimeRequestConfig: cfg.RuntimeRequestConfig,
+	}
+	// custom go std clients bring their own timeout, see clientState.timeout
+	if !current.customStdClient {
+		next.timeout = cfg.ClientConfig.ClientTimeout
+	}
+	c.state.Store(next)
+	return nil
+}
+
+// the poll interval of WatchConfig when the given one is not positive
+const defaultWatchInterval = 30 * time.Second
+
+// WatchConfig polls source every interval and reloads the client with the config it returns, it blocks
+// until ctx is done so it is meant to be run in its own goroutine. errors of the source or the reload are
+// passed to onError (if given) and the client keeps its current config. an interval that is not positive
+// defaults to 30s.
+func (c *Client) WatchConfig(ctx context.Context, interval time.Duration, source func() (ConfigOptions, error), onError func(error)) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		co, err := source()
+		if err == nil {
+			err = c.Reload(co)
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
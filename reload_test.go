@@ -0,0 +1,201 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	options := func(version string, timeout time.Duration) ConfigOptions {
+		return ConfigOptions{
+			ClientOptions: []ClientOption{WithClientTimeout(timeout)},
+			StaticRequestOptions: []StaticRequestOption{
+				WithURL(server.URL),
+				WithStaticHeader("X-Version", version),
+			},
+		}
+	}
+	hc, err := NewHTTPClient(MergeConfigOptions(options("v1", time.Second), ConfigOptions{
+		ClientOptions: []ClientOption{WithDefaultTransport()},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	transport := hc.state.Load().stdClient.Transport
+
+	if err := hc.Send(context.Background(), WithPath("/slow"), WithResponseBody(&bytes.Buffer{})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := hc.Reload(options("v2", 50*time.Millisecond)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hc.state.Load().stdClient.Transport != transport {
+		t.Errorf("expected the transport to be kept across reloads")
+	}
+	err = hc.Send(context.Background(), WithPath("/slow"), WithResponseBody(&bytes.Buffer{}))
+	if !IsTimeout(err) {
+		t.Errorf("expected the reloaded timeout to apply, got %v", err)
+	}
+
+	// invalid reloads don't take effect
+	bad := options("v3", time.Second)
+	bad.StaticRequestOptions = append(bad.StaticRequestOptions, WithHostPort("localhost"))
+	var configErr *ConfigError
+	if err := hc.Reload(bad); !errors.As(err, &configErr) {
+		t.Errorf("expected config error, got %v", err)
+	}
+	if err := hc.Reload(MergeConfigOptions(options("v3", time.Second), ConfigOptions{ClientOptions: []ClientOption{WithGoStdClient(server.Client())}})); err == nil {
+		t.Errorf("expected error to be set as the client cannot be reloaded")
+	}
+	if v := hc.state.Load().staticRequestConfig.Headers.Get("X-Version"); v != "v2" {
+		t.Errorf("expected %v, got %v", "v2", v)
+	}
+
+	// the transport settings are ignored, the connection pool is kept
+	if err := hc.Reload(MergeConfigOptions(options("v4", time.Second), ConfigOptions{ClientOptions: []ClientOption{WithDefaultTransport()}})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hc.state.Load().stdClient.Transport != transport {
+		t.Errorf("expected the transport to be kept across reloads")
+	}
+	if v := hc.state.Load().staticRequestConfig.Headers.Get("X-Version"); v != "v4" {
+		t.Errorf("expected %v, got %v", "v4", v)
+	}
+}
+
+func TestReloadTimeoutOfClientWithoutTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	options := func(timeout time.Duration) ConfigOptions {
+		return ConfigOptions{
+			ClientOptions:        []ClientOption{WithClientTimeout(timeout)},
+			StaticRequestOptions: []StaticRequestOption{WithURL(server.URL)},
+		}
+	}
+	hc, err := NewHTTPClient(options(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := hc.Reload(options(50 * time.Millisecond)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = hc.Send(context.Background(), WithResponseBody(&bytes.Buffer{}))
+	if !IsTimeout(err) {
+		t.Errorf("expected the reloaded timeout to apply, got %v", err)
+	}
+}
+
+func TestWatchFileConfigWithTransportSettings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	fileConfig := func(version string) ConfigOptions {
+		raw := []byte(`{
+			"host": "` + strings.TrimPrefix(server.URL, "http://") + `",
+			"transport": {"max_idle_conns_per_host": 32},
+			"headers": {"x-version": "` + version + `"}
+		}`)
+		fc, err := ParseFileConfigJSON(raw)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		co, err := fc.ConfigOptions()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return co
+	}
+	hc, err := NewHTTPClient(fileConfig("v1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	errs := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hc.WatchConfig(ctx, time.Millisecond, func() (ConfigOptions, error) {
+		return fileConfig("v2"), nil
+	}, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+
+	deadline := time.After(time.Second)
+	for hc.state.Load().staticRequestConfig.Headers.Get("X-Version") != "v2" {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error: %v", err)
+		case <-deadline:
+			t.Fatalf("expected the config to be reloaded")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestWatchConfigNonPositiveInterval(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	hc := newTestClient(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hc.WatchConfig(ctx, 0, func() (ConfigOptions, error) { return ConfigOptions{}, nil }, nil)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the watch to stop")
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	hc := newTestClient(t, server)
+
+	var calls int32
+	reloaded := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hc.WatchConfig(ctx, time.Millisecond, func() (ConfigOptions, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return ConfigOptions{}, errors.New("config service is down")
+		}
+		return ConfigOptions{StaticRequestOptions: []StaticRequestOption{WithURL(server.URL), WithStaticHeader("X-Version", "v2")}}, nil
+	}, func(err error) {})
+
+	go func() {
+		for hc.state.Load().staticRequestConfig.Headers.Get("X-Version") != "v2" {
+			time.Sleep(time.Millisecond)
+		}
+		close(reloaded)
+	}()
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the config to be reloaded")
+	}
+}
@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Response is returned by SendRaw. unlike Send it doesn't enforce a status code or a response
+// destination, the caller gets the status, headers and a lazily read body and decides what to do.
+// the caller is expected to call Close (or one of the helpers that consume the body) once done.
+type Response struct {
+	StatusCode    int
+	Status        string
+	Proto         string
+	Header        http.Header
+	ContentLength int64
+	// URL is the final url of the request, after following redirects
+	URL *url.URL
+
+	raw      *http.Response
+	body     []byte
+	read     bool
+	recorder *timingsRecorder
+}
+
+func newResponse(resp *http.Response) *Response {
+	var u *url.URL
+	if resp.Request != nil {
+		u = resp.Request.URL
+	}
+	return &Response{
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Proto:         resp.Proto,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+		URL:           u,
+		raw:           resp,
+		recorder:      timingsOf(resp),
+	}
+}
+
+// Timings returns the timing breakdown of the request, Total is set once the body is read or closed.
+func (r *Response) Timings() Timings {
+	if r.recorder == nil {
+		return Timings{}
+	}
+	return r.recorder.snapshot()
+}
+
+// Trailer returns the response trailers. trailers are only known once the body is fully read,
+// so this returns an empty header until Bytes, String, JSON or a full read of Body is done.
+func (r *Response) Trailer() http.Header {
+	if r.raw.Trailer == nil {
+		return http.Header{}
+	}
+	return r.raw.Trailer
+}
+
+// Body returns the response body as a stream. once any of Bytes, String or JSON is called
+// the body is served from memory instead.
+func (r *Response) Body() io.Reader {
+	if r.read {
+		return bytes.NewReader(r.body)
+	}
+	return r.raw.Body
+}
+
+// Bytes reads the whole body and closes it. subsequent calls return the same bytes.
+func (r *Response) Bytes() ([]byte, error) {
+	if r.read {
+		return r.body, nil
+	}
+	defer r.raw.Body.Close()
+	body, err := io.ReadAll(r.raw.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.body = body
+	r.read = true
+	return r.body, nil
+}
+
+func (r *Response) String() (string, error) {
+	body, err := r.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+func (r *Response) JSON(v interface{}) error {
+	body, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// Close closes the underlying body, it is safe to call it after the body is consumed.
+func (r *Response) Close() error {
+	if r.read {
+		return nil
+	}
+	return r.raw.Body.Close()
+}
+
+// Raw returns the underlying go std response, useful for the rare cases where the fields above aren't enough.
+func (r *Response) Raw() *http.Response {
+	return r.raw
+}
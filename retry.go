@@ -0,0 +1,266 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryMode decides who executes the EnvoyRetryPolicy set with WithEnvoyRetryPolicy.
+type RetryMode string
+
+const (
+	// RetryModeSidecar only emits the x-envoy-* headers and leaves the retries to the envoy sidecar. this is the default.
+	RetryModeSidecar RetryMode = "sidecar"
+	// RetryModeInProcess executes the retry policy in the client itself and doesn't emit the x-envoy-* retry headers.
+	// useful when the service runs without a sidecar (local dev, batch jobs, tests)
+	RetryModeInProcess RetryMode = "in-process"
+	// RetryModeSidecarAndInProcess emits the headers and also retries in process.
+	// note that the retries multiply, i.e. upto (MaxRetries+1)^2 requests can reach the upstream.
+	RetryModeSidecarAndInProcess RetryMode = "sidecar-and-in-process"
+)
+
+func (m RetryMode) inProcess() bool {
+	return m == RetryModeInProcess || m == RetryModeSidecarAndInProcess
+}
+
+func (m RetryMode) sidecar() bool {
+	return m == "" || m == RetryModeSidecar || m == RetryModeSidecarAndInProcess
+}
+
+func WithRetryMode(mode RetryMode) StaticRequestOption {
+	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
+		err := validateRetryMode(mode)
+		if err != nil {
+			return c, err
+		}
+		c.RetryMode = mode
+		return c, nil
+	}
+}
+
+func validateRetryMode(mode RetryMode) error {
+	switch mode {
+	case RetryModeSidecar, RetryModeInProcess, RetryModeSidecarAndInProcess:
+		return nil
+	}
+	return fmt.Errorf("invalid retry mode %q", mode)
+}
+
+type attemptContextKey struct{}
+
+// AttemptFromContext returns the attempt number of the request, starting from 1 for the first attempt.
+// it is meant to be used by middlewares, it returns 0 if the context doesn't belong to a request sent by the Client.
+func AttemptFromContext(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptContextKey{}).(int)
+	return attempt
+}
+
+// sendWithRetries sends the request through roundTrip, retrying as per the policy. the returned
+// response's body keeps the timeouts of the policy alive until it is closed.
+func (st *clientState) sendWithRetries(ctx context.Context, rrc RuntimeRequestConfig, policy EnvoyRetryPolicy, roundTrip RoundTripFunc) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if policy.TotalTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, policy.TotalTimeout)
+	}
+
+	backoff, baseInterval, maxInterval := policy.backoffFunc()
+	var prevWait time.Duration
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancelAttempt := ctx, context.CancelFunc(func() {})
+		if policy.PerTryTimeout > 0 {
+			attemptCtx, cancelAttempt = context.WithTimeout(ctx, policy.PerTryTimeout)
+		}
+		attemptCtx = context.WithValue(attemptCtx, attemptContextKey{}, attempt)
+		attemptCtx = withTimings(attemptCtx)
+
+		req, err := st.buildRequest(attemptCtx, rrc)
+		if err != nil {
+			cancelAttempt()
+			cancel()
+			return nil, err
+		}
+		resp, err := roundTrip(req)
+		if err != nil && req.Body != nil {
+			// the go std client closes the body, but a middleware may fail without calling it. a streamed body
+			// would then leak its encoder, e.g. the goroutine of WithMultipart that blocks on the pipe.
+			_ = req.Body.Close()
+		}
+
+		retry := attempt <= int(policy.MaxRetries) && ctx.Err() == nil && shouldRetry(policy, resp, err)
+		// the policy allows a retry but the body was consumed by this attempt, the caller is told why it wasn't retried
+		notReplayed := retry && rrc.bodyOneShot
+		if notReplayed {
+			retry = false
+		}
+		var wait time.Duration
+		if retry {
+			wait = backoff(attempt, baseInterval, maxInterval, prevWait)
+			if upstreamWait, ok := rateLimitedBackoff(resp, time.Now()); ok {
+				wait = upstreamWait
+			}
+			prevWait = wait
+			// don't bother retrying if the wait itself runs past the total timeout
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				retry = false
+			}
+		}
+		if !retry {
+			if err != nil {
+				cancelAttempt()
+				cancel()
+				if notReplayed {
+					err = withNotReplayed(err)
+				}
+				return nil, err
+			}
+			if scope, ok := ctx.Value(requestScopeKey{}).(*requestScope); ok && notReplayed {
+				scope.retrySkipped = ErrBodyNotReplayable
+			}
+			resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancels: []context.CancelFunc{cancelAttempt, cancel}}
+			return resp, nil
+		}
+
+		if resp != nil {
+			drainAndClose(resp.Body)
+		}
+		cancelAttempt()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			cancel()
+			if err == nil {
+				err = ctx.Err()
+			}
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// retrySkippedOf returns why the response wasn't retried although the policy allows it, nil if it was retried
+// as far as the policy goes.
+func retrySkippedOf(resp *http.Response) error {
+	if resp.Request == nil {
+		return nil
+	}
+	scope, _ := resp.Request.Context().Value(requestScopeKey{}).(*requestScope)
+	if scope == nil {
+		return nil
+	}
+	return scope.retrySkipped
+}
+
+// shouldRetry classifies the outcome of an attempt as per the RetryOn codes of the policy,
+// mirroring the semantics of envoy's x-envoy-retry-on header.
+func shouldRetry(policy EnvoyRetryPolicy, resp *http.Response, err error) bool {
+	if err != nil {
+		for _, code := range policy.RetryOn {
+			switch code {
+			case RetryOn5xx, RetryOnGatewayError:
+				// envoy treats a missing response (connect failure, reset, per try timeout) as 5xx/gateway error
+				if isConnectFailure(err) || isReset(err) || isRefusedStream(err) || isPerTryTimeout(err) {
+					return true
+				}
+			case RetryOnReset:
+				if isReset(err) || isPerTryTimeout(err) {
+					return true
+				}
+			case RetryOnConnectFailure:
+				if isConnectFailure(err) {
+					return true
+				}
+			case RetryOnRefusedStream:
+				if isRefusedStream(err) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for _, code := range policy.RetryOn {
+		switch code {
+		case RetryOn5xx:
+			if resp.StatusCode >= 500 && resp.StatusCode <= 599 {
+				return true
+			}
+		case RetryOnGatewayError:
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				return true
+			}
+		case RetryOnRetriable4xx:
+			if resp.StatusCode == http.StatusConflict {
+				return true
+			}
+		case RetryOnEnvoyRatelimited:
+			if resp.Header.Get("x-envoy-ratelimited") != "" {
+				return true
+			}
+		}
+	}
+	for _, statusCode := range policy.RetriableStatusCodes {
+		if resp.StatusCode == int(statusCode) {
+			return true
+		}
+	}
+	for _, header := range policy.RetriableHeaders {
+		if resp.Header.Get(header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func isConnectFailure(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func isReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func isRefusedStream(err error) bool {
+	// the http2 errors of the std library are not exported
+	return strings.Contains(err.Error(), "REFUSED_STREAM")
+}
+
+func isPerTryTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+func drainAndClose(body io.ReadCloser) {
+	// drain a bit of the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 4<<10))
+	_ = body.Close()
+}
+
+// cancelOnCloseBody releases the request contexts once the caller is done with the body
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancels []context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	for _, cancel := range b.cancels {
+		cancel()
+	}
+	return err
+}
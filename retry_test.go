@@ -0,0 +1,245 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestInProcessRetries(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if string(body) != `{"a":1}` {
+			t.Errorf("expected the body to be replayed on every attempt, got %v", string(body))
+		}
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	var attempts []int
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{
+			WithGoStdClient(server.Client()),
+			WithMiddlewares(func(next RoundTripFunc) RoundTripFunc {
+				return func(req *http.Request) (*http.Response, error) {
+					attempts = append(attempts, AttemptFromContext(req.Context()))
+					return next(req)
+				}
+			}),
+		},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			WithEnvoyRetryPolicy(EnvoyRetryPolicy{
+				MaxRetries:    3,
+				TotalTimeout:  5 * time.Second,
+				PerTryTimeout: time.Second,
+				RetryOn:       []RetryOnCode{RetryOnGatewayError},
+			}),
+			WithRetryMode(RetryModeInProcess),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = hc.Send(context.Background(), WithMethod(MethodPost), WithRequestJSON(map[string]int{"a": 1}), WithResponseBody(buf))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != "done" {
+		t.Errorf("expected %v, got %v", "done", buf.String())
+	}
+	if expected := []int{1, 2, 3}; !reflect.DeepEqual(attempts, expected) {
+		t.Errorf("expected %v, got %v", expected, attempts)
+	}
+}
+
+func TestInProcessRetriesExhausted(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{
+			WithGoStdClient(server.Client()),
+		},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			WithEnvoyRetryPolicy(EnvoyRetryPolicy{
+				MaxRetries:           2,
+				TotalTimeout:         5 * time.Second,
+				PerTryTimeout:        time.Second,
+				RetriableStatusCodes: []uint16{http.StatusTooManyRequests},
+			}),
+			WithRetryMode(RetryModeInProcess),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := hc.SendRaw(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected %v, got %v", http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if v := atomic.LoadInt32(&hits); v != 3 {
+		t.Errorf("expected %v attempts, got %v", 3, v)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	policy := EnvoyRetryPolicy{RetryOn: []RetryOnCode{RetryOn5xx, RetryOnRetriable4xx}, RetriableHeaders: []string{"X-Retry-Me"}}
+	testcases := []struct {
+		status   int
+		header   http.Header
+		expected bool
+	}{
+		{status: http.StatusOK, expected: false},
+		{status: http.StatusInternalServerError, expected: true},
+		{status: http.StatusConflict, expected: true},
+		{status: http.StatusBadRequest, expected: false},
+		{status: http.StatusBadRequest, header: http.Header{"X-Retry-Me": {"1"}}, expected: true},
+	}
+	for idx, testcase := range testcases {
+		resp := &http.Response{StatusCode: testcase.status, Header: testcase.header}
+		if resp.Header == nil {
+			resp.Header = http.Header{}
+		}
+		if got := shouldRetry(policy, resp, nil); got != testcase.expected {
+			t.Errorf("expected %v, got %v for test case %v", testcase.expected, got, idx)
+		}
+	}
+}
+
+func TestShouldRetryErrors(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	connectFailure := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	perTryTimeout := &url.Error{Op: "Get", URL: "http://localhost", Err: context.DeadlineExceeded}
+	testcases := []struct {
+		retryOn  RetryOnCode
+		err      error
+		expected bool
+	}{
+		{retryOn: RetryOnReset, err: reset, expected: true},
+		{retryOn: RetryOnReset, err: perTryTimeout, expected: true},
+		{retryOn: RetryOnReset, err: connectFailure, expected: false},
+		{retryOn: RetryOnConnectFailure, err: connectFailure, expected: true},
+		{retryOn: RetryOnConnectFailure, err: reset, expected: false},
+		{retryOn: RetryOnConnectFailure, err: perTryTimeout, expected: false},
+		{retryOn: RetryOn5xx, err: reset, expected: true},
+		{retryOn: RetryOn5xx, err: connectFailure, expected: true},
+		{retryOn: RetryOn5xx, err: perTryTimeout, expected: true},
+		{retryOn: RetryOnGatewayError, err: perTryTimeout, expected: true},
+		{retryOn: RetryOnRetriable4xx, err: reset, expected: false},
+		{retryOn: RetryOnGatewayError, err: errors.New("malformed response"), expected: false},
+	}
+	for idx, testcase := range testcases {
+		policy := EnvoyRetryPolicy{RetryOn: []RetryOnCode{testcase.retryOn}}
+		if got := shouldRetry(policy, nil, testcase.err); got != testcase.expected {
+			t.Errorf("expected %v, got %v for test case %v", testcase.expected, got, idx)
+		}
+	}
+}
+
+func TestInProcessRetriesOnErrors(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/reset" && atomic.AddInt32(&hits, 1) == 1:
+			// the connection is reset without a response
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			_ = conn.(*net.TCPConn).SetLinger(0)
+			_ = conn.Close()
+		case r.URL.Path == "/slow" && atomic.AddInt32(&hits, 1) == 1:
+			// the first attempt outlives the per try timeout
+			time.Sleep(300 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedURL := closed.URL
+	closed.Close()
+
+	testcases := []struct {
+		url      string
+		path     string
+		retryOn  RetryOnCode
+		attempts int32
+		ok       bool
+	}{
+		{url: server.URL, path: "/reset", retryOn: RetryOnReset, attempts: 2, ok: true},
+		{url: server.URL, path: "/reset", retryOn: RetryOnConnectFailure, attempts: 1, ok: false},
+		{url: server.URL, path: "/slow", retryOn: RetryOnGatewayError, attempts: 2, ok: true},
+		{url: server.URL, path: "/slow", retryOn: RetryOnConnectFailure, attempts: 1, ok: false},
+		{url: closedURL, retryOn: RetryOnConnectFailure, attempts: 3, ok: false},
+		{url: closedURL, retryOn: RetryOn5xx, attempts: 3, ok: false},
+		{url: closedURL, retryOn: RetryOnReset, attempts: 1, ok: false},
+	}
+	for idx, testcase := range testcases {
+		atomic.StoreInt32(&hits, 0)
+		var attempts int32
+		hc, err := NewHTTPClient(ConfigOptions{
+			ClientOptions: []ClientOption{
+				WithMiddlewares(func(next RoundTripFunc) RoundTripFunc {
+					return func(req *http.Request) (*http.Response, error) {
+						atomic.AddInt32(&attempts, 1)
+						return next(req)
+					}
+				}),
+			},
+			StaticRequestOptions: []StaticRequestOption{
+				WithURL(testcase.url),
+				WithEnvoyRetryPolicy(EnvoyRetryPolicy{
+					MaxRetries:    2,
+					TotalTimeout:  5 * time.Second,
+					PerTryTimeout: 100 * time.Millisecond,
+					RetryOn:       []RetryOnCode{testcase.retryOn},
+					BaseInterval:  time.Millisecond,
+				}),
+				WithRetryMode(RetryModeInProcess),
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = hc.Send(context.Background(), WithPath(testcase.path), WithResponseBody(io.Discard))
+		if (err == nil) != testcase.ok {
+			t.Errorf("test case %v: expected ok %v, got %v", idx, testcase.ok, err)
+		}
+		if got := atomic.LoadInt32(&attempts); got != testcase.attempts {
+			t.Errorf("test case %v: expected %v attempts, got %v", idx, testcase.attempts, got)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the reconnection delay until the server sets one with the retry field
+const defaultSSERetry = 3 * time.Second
+
+// lines of an event stream longer than this fail the stream
+const maxSSELine = 1 << 20
+
+// Event is a server-sent event
+type Event struct {
+	// ID is the last event id seen on the stream when the event was dispatched
+	ID string
+	// Event is the event type, message unless the server sets one
+	Event string
+	Data  string
+	// Retry is the reconnection delay set by the event, zero if it didn't set one
+	Retry time.Duration
+}
+
+// EventStream is a server-sent events stream, the events are received from Events until the stream ends.
+// the stream reconnects with the Last-Event-ID header when the connection is lost, and it ends when the
+// context is done, the stream is closed, the server responds with 204 or a reconnect fails with anything
+// but a transport error.
+type EventStream struct {
+	events chan Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	st  *clientState
+	rrc RuntimeRequestConfig
+
+	mu          sync.Mutex
+	err         error
+	closed      bool
+	lastEventID string
+	retry       time.Duration
+}
+
+// errStreamEnded is returned by connect when the server asks the client to stop reconnecting
+var errStreamEnded = errors.New("stream ended by the server")
+
+// Stream sends the request and returns the server-sent events stream of the response. the request is built
+// the same way as with Send, the Accept header is set to text/event-stream. neither the client timeout nor the
+// timeouts of an in process retry policy apply to streams, use WithReadIdleTimeout to detect connections that
+// went silent.
+func (c *Client) Stream(ctx context.Context, opts ...RuntimeRequestOption) (*EventStream, error) {
+	st := c.streamState()
+	rrc, err := st.buildRuntimeRequestConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	rrc.Headers.Set("Accept", "text/event-stream")
+	rrc.Headers.Set("Cache-Control", "no-cache")
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &EventStream{
+		events: make(chan Event),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		st:     st,
+		rrc:    rrc,
+		retry:  defaultSSERetry,
+	}
+	resp, err := s.connect(ctx)
+	if err != nil && err != errStreamEnded {
+		cancel()
+		return nil, err
+	}
+	go s.run(ctx, resp)
+	return s, nil
+}
+
+// Events returns the channel of the events, it is closed when the stream ends.
+func (s *EventStream) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns the error that ended the stream, the context error if the context of the caller is done. it is
+// nil while the stream is running and when the stream was closed or ended by the server.
+func (s *EventStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// LastEventID returns the id of the last event received, it is sent as Last-Event-ID on reconnects.
+func (s *EventStream) LastEventID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastEventID
+}
+
+// Close ends the stream and waits until the connection is released.
+func (s *EventStream) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *EventStream) connect(ctx context.Context) (*http.Response, error) {
+	rrc := s.rrc
+	if lastEventID := s.LastEventID(); lastEventID != "" {
+		rrc.Headers = rrc.Headers.Clone()
+		rrc.Headers.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := s.st.openStream(ctx, rrc)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		_ = resp.Body.Close()
+		return nil, errStreamEnded
+	}
+	if contentType := mediaType(resp.Header.Get("Content-Type")); contentType != "text/event-stream" {
+		_ = resp.Body.Close()
+		return nil, s.st.decodeError(rrc, resp, fmt.Errorf("expected a text/event-stream response, got %q", contentType))
+	}
+	return resp, nil
+}
+
+func (s *EventStream) run(ctx context.Context, resp *http.Response) {
+	defer close(s.done)
+	defer close(s.events)
+	defer s.cancel()
+
+	for resp != nil {
+		err := s.read(ctx, resp.Body)
+		_ = resp.Body.Close()
+		if ctx.Err() != nil {
+			break
+		}
+		if errors.Is(err, bufio.ErrTooLong) {
+			s.fail(s.st.decodeError(s.rrc, resp, err))
+			return
+		}
+		// the connection is lost or the server ended the response, either way the stream resumes with a reconnect
+		resp, err = s.reconnect(ctx)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		// ended by the context of the caller, or by the server with a 204 in which case this is nil
+		s.err = ctx.Err()
+	}
+}
+
+func (s *EventStream) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// reconnect waits for the reconnection delay and connects again, as long as the connection fails with transport errors.
+// it returns a nil response when the stream should end without an error.
+func (s *EventStream) reconnect(ctx context.Context) (*http.Response, error) {
+	for {
+		s.mu.Lock()
+		retry := s.retry
+		s.mu.Unlock()
+		timer := time.NewTimer(retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil
+		case <-timer.C:
+		}
+
+		resp, err := s.connect(ctx)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil || err == errStreamEnded {
+			return nil, nil
+		}
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) {
+			return nil, err
+		}
+	}
+}
+
+// read dispatches the events of the body until it ends, the error is nil when the body ended cleanly
+func (s *EventStream) read(ctx context.Context, body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxSSELine)
+	scanner.Split(scanSSELines)
+
+	var eventType string
+	var data strings.Builder
+	var retry time.Duration
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// an empty line dispatches the event, events without data are dropped
+			if data.Len() > 0 {
+				event := Event{
+					ID:    s.LastEventID(),
+					Event: eventType,
+					Data:  strings.TrimSuffix(data.String(), "\n"),
+					Retry: retry,
+				}
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				select {
+				case s.events <- event:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			eventType, retry = "", 0
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// comment, usually a keep alive
+			continue
+		}
+		field, value := line, ""
+		if idx := strings.IndexByte(line, ':'); idx >= 0 {
+			field, value = line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.mu.Lock()
+				s.lastEventID = value
+				s.mu.Unlock()
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 32); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+				s.mu.Lock()
+				s.retry = retry
+				s.mu.Unlock()
+			}
+		}
+	}
+	// an event that isn't terminated by an empty line is discarded
+	return scanner.Err()
+}
+
+// scanSSELines splits lines terminated by \r\n, \n or \r
+func scanSSELines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if idx := bytes.IndexAny(data, "\r\n"); idx >= 0 {
+		if data[idx] == '\n' {
+			return idx + 1, data[:idx], nil
+		}
+		// a \r at the end of the buffer may be followed by a \n in the next read
+		if idx+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if idx+1 < len(data) && data[idx+1] == '\n' {
+			return idx + 2, data[:idx], nil
+		}
+		return idx + 1, data[:idx], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
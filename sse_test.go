@@ -0,0 +1,186 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("expected %v, got %v", "text/event-stream", r.Header.Get("Accept"))
+		}
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(": keep alive\nretry: 10\n\nid: 1\ndata: first\ndata:  line\n\n"))
+			// an event that isn't terminated is discarded when the connection is lost
+			_, _ = w.Write([]byte("data: lost\n"))
+		case 2:
+			if v := r.Header.Get("Last-Event-ID"); v != "1" {
+				t.Errorf("expected %v, got %v", "1", v)
+			}
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			_, _ = w.Write([]byte("event: update\r\nid: 2\r\ndata\r\ndata: {}\r\n\r\nid: 3\rid\r\r"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	hc := newTestClient(t, server)
+	stream, err := hc.Stream(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var events []Event
+	for event := range stream.Events() {
+		events = append(events, event)
+	}
+	expected := []Event{
+		{ID: "1", Event: "message", Data: "first\n line"},
+		{ID: "2", Event: "update", Data: "\n{}"},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+	if err := stream.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// an empty id resets the last event id
+	if id := stream.LastEventID(); id != "" {
+		t.Errorf("expected an empty last event id, got %v", id)
+	}
+}
+
+func TestStreamEnds(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/html" {
+			w.Header().Set("Content-Type", "text/html")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	hc := newTestClient(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := hc.Stream(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cancel()
+	for range stream.Events() {
+	}
+	if err := stream.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+
+	stream, err = hc.Stream(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = stream.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("close did not return")
+	}
+	if err := stream.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = hc.Stream(context.Background(), WithPath("/html"))
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Errorf("expected a decode error, got %v", err)
+	}
+}
+
+// newPerTryTimeoutTestClient returns a client that retries in process with the given per try timeout
+func newPerTryTimeoutTestClient(t *testing.T, server *httptest.Server, perTryTimeout time.Duration) *Client {
+	t.Helper()
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{WithGoStdClient(server.Client())},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			WithEnvoyRetryPolicy(EnvoyRetryPolicy{
+				MaxRetries:    1,
+				TotalTimeout:  2 * perTryTimeout,
+				PerTryTimeout: perTryTimeout,
+				RetryOn:       []RetryOnCode{RetryOnGatewayError},
+			}),
+			WithRetryMode(RetryModeInProcess),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return hc
+}
+
+func TestStreamOutlivesRetryPolicyTimeouts(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&connections, 1) > 1 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("retry: 10\nid: 1\ndata: first\n\n"))
+		w.(http.Flusher).Flush()
+		// the stream outlives both the per try and the total timeout of the policy
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte("id: 2\ndata: second\n\n"))
+	}))
+	defer server.Close()
+
+	hc := newPerTryTimeoutTestClient(t, server, 100*time.Millisecond)
+	stream, err := hc.Stream(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var ids []string
+	for event := range stream.Events() {
+		ids = append(ids, event.ID)
+	}
+	if expected := []string{"1", "2"}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+	if err := stream.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// the stream ended on its own and the reconnect got a 204, it wasn't cut off
+	if n := atomic.LoadInt32(&connections); n != 2 {
+		t.Errorf("expected %v, got %v", 2, n)
+	}
+}
+
+func TestScanSSELines(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("a\nb\r\nc\rd\r\r\ne"))
+	scanner.Split(scanSSELines)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if expected := []string{"a", "b", "c", "d", "", "e"}; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+}
@@ -0,0 +1,154 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// StatusRange is an inclusive range of status codes
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+func (r StatusRange) contains(code int) bool {
+	return code >= r.Min && code <= r.Max
+}
+
+var (
+	defaultExpectedStatus = []StatusRange{{Min: 200, Max: 299}}
+	// error responses without explicit status codes are decoded for any 4xx or 5xx
+	defaultErrorResponseStatus = []StatusRange{{Min: 400, Max: 599}}
+)
+
+// ErrorResponseTarget is the destination that an error response body with a matching status is decoded into.
+type ErrorResponseTarget struct {
+	Status []StatusRange
+	Target interface{}
+}
+
+func statusInRanges(code int, ranges []StatusRange) bool {
+	for _, r := range ranges {
+		if r.contains(code) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateStatusRange(min, max int) error {
+	if min < 100 || max > 599 {
+		return fmt.Errorf("invalid status range %d-%d, status codes must be in 100-599", min, max)
+	}
+	if min > max {
+		return fmt.Errorf("invalid status range %d-%d, min is greater than max", min, max)
+	}
+	return nil
+}
+
+func statusCodesToRanges(codes []int) ([]StatusRange, error) {
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("no status codes given")
+	}
+	ranges := make([]StatusRange, 0, len(codes))
+	for _, code := range codes {
+		if err := validateStatusRange(code, code); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, StatusRange{Min: code, Max: code})
+	}
+	return ranges, nil
+}
+
+// WithExpectedStatus accepts the given status codes for this request, any other status results in a StatusError.
+// it can be combined with WithStatusRange, and it overrides the expected status of the static config.
+func WithExpectedStatus(codes ...int) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		ranges, err := statusCodesToRanges(codes)
+		if err != nil {
+			return c, err
+		}
+		c.ExpectedStatus = append(c.ExpectedStatus[:len(c.ExpectedStatus):len(c.ExpectedStatus)], ranges...)
+		return c, nil
+	}
+}
+
+// WithStatusRange accepts the status codes in [min, max] for this request.
+func WithStatusRange(min, max int) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if err := validateStatusRange(min, max); err != nil {
+			return c, err
+		}
+		c.ExpectedStatus = append(c.ExpectedStatus[:len(c.ExpectedStatus):len(c.ExpectedStatus)], StatusRange{Min: min, Max: max})
+		return c, nil
+	}
+}
+
+// WithStaticExpectedStatus accepts the given status codes for all the requests that don't set their own.
+func WithStaticExpectedStatus(codes ...int) StaticRequestOption {
+	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
+		ranges, err := statusCodesToRanges(codes)
+		if err != nil {
+			return c, err
+		}
+		c.ExpectedStatus = append(c.ExpectedStatus[:len(c.ExpectedStatus):len(c.ExpectedStatus)], ranges...)
+		return c, nil
+	}
+}
+
+// WithStaticStatusRange accepts the status codes in [min, max] for all the requests that don't set their own.
+func WithStaticStatusRange(min, max int) StaticRequestOption {
+	return func(c StaticRequestConfig) (StaticRequestConfig, error) {
+		if err := validateStatusRange(min, max); err != nil {
+			return c, err
+		}
+		c.ExpectedStatus = append(c.ExpectedStatus[:len(c.ExpectedStatus):len(c.ExpectedStatus)], StatusRange{Min: min, Max: max})
+		return c, nil
+	}
+}
+
+// WithErrorResponseJSON decodes the body of an unexpected response into target when its status is one of codes,
+// or any 4xx/5xx when no codes are given. the returned StatusError carries the decoded target in ErrorResponse.
+// the first matching target wins when the option is given multiple times.
+func WithErrorResponseJSON(target interface{}, codes ...int) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if target == nil {
+			return c, fmt.Errorf("error response target is nil")
+		}
+		ranges := defaultErrorResponseStatus
+		if len(codes) > 0 {
+			var err error
+			if ranges, err = statusCodesToRanges(codes); err != nil {
+				return c, err
+			}
+		}
+		c.ErrorResponseJSON = append(c.ErrorResponseJSON[:len(c.ErrorResponseJSON):len(c.ErrorResponseJSON)], ErrorResponseTarget{Status: ranges, Target: target})
+		return c, nil
+	}
+}
+
+func expectedStatus(src StaticRequestConfig, rrc RuntimeRequestConfig) []StatusRange {
+	if len(rrc.ExpectedStatus) > 0 {
+		return rrc.ExpectedStatus
+	}
+	if len(src.ExpectedStatus) > 0 {
+		return src.ExpectedStatus
+	}
+	return defaultExpectedStatus
+}
+
+func errorResponseTarget(rrc RuntimeRequestConfig, code int) interface{} {
+	for _, target := range rrc.ErrorResponseJSON {
+		if statusInRanges(code, target.Status) {
+			return target.Target
+		}
+	}
+	return nil
+}
+
+// hasNoBody reports whether the response can't carry a body that is worth decoding
+func hasNoBody(method string, resp *http.Response) bool {
+	return resp.StatusCode == http.StatusNoContent ||
+		resp.StatusCode == http.StatusNotModified ||
+		method == http.MethodHead
+}
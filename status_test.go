@@ -0,0 +1,91 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestExpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+		w.WriteHeader(code)
+		if code != http.StatusNoContent {
+			_, _ = w.Write([]byte(`{"message":"status ` + r.URL.Query().Get("code") + `"}`))
+		}
+	}))
+	defer server.Close()
+	hc := newTestClient(t, server)
+
+	type apiResp struct {
+		Message string `json:"message"`
+	}
+	send := func(code int, opts ...RuntimeRequestOption) (apiResp, error) {
+		var resp apiResp
+		opts = append([]RuntimeRequestOption{WithQueryParam("code", strconv.Itoa(code)), WithResponseJSON(&resp)}, opts...)
+		err := hc.Send(context.Background(), opts...)
+		return resp, err
+	}
+
+	// 2xx is accepted by default
+	if resp, err := send(http.StatusCreated); err != nil || resp.Message != "status 201" {
+		t.Errorf("expected 201 to be accepted, got %v %v", resp, err)
+	}
+	// 204 skips decoding
+	if _, err := send(http.StatusNoContent); err != nil {
+		t.Errorf("expected 204 to be accepted, got %v", err)
+	}
+	// explicit codes override the default
+	if _, err := send(http.StatusCreated, WithExpectedStatus(http.StatusOK)); StatusCodeOf(err) != http.StatusCreated {
+		t.Errorf("expected 201 to be rejected, got %v", err)
+	}
+	if _, err := send(http.StatusFound, WithExpectedStatus(http.StatusOK), WithStatusRange(300, 399)); err != nil {
+		t.Errorf("expected 302 to be accepted, got %v", err)
+	}
+
+	// error responses are decoded into the error
+	var apiErr apiResp
+	_, err := send(http.StatusBadRequest, WithErrorResponseJSON(&apiErr))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	if statusErr.ErrorResponse != &apiErr || apiErr.Message != "status 400" {
+		t.Errorf("expected error response to be decoded, got %v", apiErr)
+	}
+	// error responses are decoded only for the given codes
+	var conflictErr apiResp
+	_, err = send(http.StatusBadRequest, WithErrorResponseJSON(&conflictErr, http.StatusConflict))
+	if !errors.As(err, &statusErr) || statusErr.ErrorResponse != nil || conflictErr.Message != "" {
+		t.Errorf("expected error response to not be decoded, got %v", err)
+	}
+
+	if _, err := WithStatusRange(300, 200)(RuntimeRequestConfig{}); err == nil {
+		t.Errorf("expected error to be set as the range is invalid")
+	}
+}
+
+func TestStaticExpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions:        []ClientOption{WithGoStdClient(server.Client())},
+		StaticRequestOptions: []StaticRequestOption{WithURL(server.URL), WithStaticExpectedStatus(http.StatusOK)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var resp struct{}
+	if err := hc.Send(context.Background(), WithResponseJSON(&resp)); StatusCodeOf(err) != http.StatusAccepted {
+		t.Errorf("expected 202 to be rejected, got %v", err)
+	}
+	if err := hc.Send(context.Background(), WithResponseJSON(&resp), WithExpectedStatus(http.StatusAccepted)); err != nil {
+		t.Errorf("expected 202 to be accepted, got %v", err)
+	}
+}
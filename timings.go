@@ -0,0 +1,156 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings is the breakdown of the time spent on the attempt that produced the response. the phases that didn't
+// happen are zero, e.g. DNS, Connect and TLSHandshake when the connection is reused from the pool.
+type Timings struct {
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// GotConn is the time from the start of the attempt until it got a connection, new or reused
+	GotConn    time.Duration
+	ConnReused bool
+	// TTFB is the time from the start of the attempt until the first byte of the response
+	TTFB time.Duration
+	// Total is the time from the start of the attempt until the body is read or closed, zero until then
+	Total time.Duration
+}
+
+// WithTimingsHook calls hook with the timings of the request once its response body is read or closed.
+func WithTimingsHook(hook func(Timings)) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		if hook == nil {
+			return c, errors.New("timings hook is nil")
+		}
+		c.TimingsHook = hook
+		return c, nil
+	}
+}
+
+type timingsContextKey struct{}
+
+// timingsRecorder records the timings of an attempt through httptrace
+type timingsRecorder struct {
+	mu                     sync.Mutex
+	start                  time.Time
+	dnsStart, connectStart time.Time
+	tlsStart               time.Time
+	timings                Timings
+	finished               bool
+}
+
+// withTimings returns a context that records the timings of the attempt sent with it
+func withTimings(ctx context.Context) context.Context {
+	r := &timingsRecorder{start: time.Now()}
+	ctx = context.WithValue(ctx, timingsContextKey{}, r)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings.DNS = time.Since(r.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			// with multiple addresses the dials race, the connect time spans all of them
+			if r.connectStart.IsZero() {
+				r.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(string, string, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings.Connect = time.Since(r.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings.TLSHandshake = time.Since(r.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings.GotConn = time.Since(r.start)
+			r.timings.ConnReused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timings.TTFB = time.Since(r.start)
+		},
+	})
+}
+
+// timingsOf returns the recorder of the attempt that produced the response, nil if there is none
+func timingsOf(resp *http.Response) *timingsRecorder {
+	if resp.Request == nil {
+		return nil
+	}
+	r, _ := resp.Request.Context().Value(timingsContextKey{}).(*timingsRecorder)
+	return r
+}
+
+func (r *timingsRecorder) snapshot() Timings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.timings
+}
+
+// finish sets the total time, it reports false if it was already set
+func (r *timingsRecorder) finish() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return false
+	}
+	r.finished = true
+	r.timings.Total = time.Since(r.start)
+	return true
+}
+
+// timingsBody finishes the timings when the body is read to the end or closed, whichever comes first
+type timingsBody struct {
+	io.ReadCloser
+	recorder *timingsRecorder
+	hook     func(Timings)
+}
+
+func (b *timingsBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *timingsBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *timingsBody) finish() {
+	if b.recorder.finish() && b.hook != nil {
+		b.hook(b.recorder.snapshot())
+	}
+}
@@ -0,0 +1,56 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte("timed"))
+	}))
+	defer server.Close()
+	hc := newTestClient(t, server)
+
+	var hooked []Timings
+	for i := 0; i < 2; i++ {
+		err := hc.Send(context.Background(), WithResponseBody(io.Discard), WithTimingsHook(func(timings Timings) {
+			hooked = append(hooked, timings)
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(hooked) != 2 {
+		t.Fatalf("expected %v, got %v", 2, len(hooked))
+	}
+	first, second := hooked[0], hooked[1]
+	if first.ConnReused || first.Connect <= 0 || first.TLSHandshake <= 0 {
+		t.Errorf("expected a new tls connection, got %+v", first)
+	}
+	if first.TTFB < 10*time.Millisecond || first.Total < first.TTFB || first.GotConn > first.TTFB {
+		t.Errorf("expected got conn <= ttfb <= total, got %+v", first)
+	}
+	if !second.ConnReused || second.Connect != 0 || second.TLSHandshake != 0 {
+		t.Errorf("expected a reused connection, got %+v", second)
+	}
+
+	resp, err := hc.SendRaw(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if timings := resp.Timings(); timings.TTFB <= 0 || timings.Total != 0 {
+		t.Errorf("expected ttfb without total before the body is read, got %+v", timings)
+	}
+	if _, err := resp.Bytes(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if timings := resp.Timings(); timings.Total < timings.TTFB {
+		t.Errorf("expected the total once the body is read, got %+v", timings)
+	}
+}
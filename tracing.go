@@ -0,0 +1,208 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// SpanContext identifies a span within a trace, it is what gets propagated to the upstream.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string
+}
+
+// IsValid reports whether the trace and span ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats the span context as a W3C traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value, e.g. to continue the trace of an incoming request
+// with ContextWithSpanContext.
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	version, errVersion := hex.DecodeString(parts[0])
+	traceID, errTrace := hex.DecodeString(parts[1])
+	spanID, errSpan := hex.DecodeString(parts[2])
+	flags, errFlags := hex.DecodeString(parts[3])
+	if errVersion != nil || errTrace != nil || errSpan != nil || errFlags != nil || len(version) != 1 ||
+		len(traceID) != 16 || len(spanID) != 8 || len(flags) != 1 {
+		return sc, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a context that carries the span context as the parent of the spans started from it.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, the zero value if there is none.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// SpanContext returns the context of the span, it is injected into the request headers
+	SpanContext() SpanContext
+	SetAttributes(fields ...Field)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts the client spans of the tracing middleware. it is small so that an OpenTelemetry tracer can be
+// adapted without this module importing it, the adapter starts an otel span of kind client from ctx and
+// converts its span context. see W3CTracer for the builtin tracer.
+type Tracer interface {
+	// Start starts a span as a child of the span carried by ctx, if any, and returns a context carrying the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// TracingConfig configures the tracing middleware, the traceparent and tracestate headers are always injected.
+type TracingConfig struct {
+	// B3 also injects the X-B3-TraceId, X-B3-SpanId and X-B3-Sampled headers for the upstreams that only speak zipkin
+	B3 bool
+	// RequestID injects a random x-request-id header that envoy uses to correlate the logs and traces of the
+	// request. it is the same across the attempts of a request and the header set on the request is kept.
+	RequestID bool
+}
+
+// NewTracingMiddleware returns a middleware that starts a client span for every attempt of a request, as a child
+// of the span carried by the context passed to Send. the span records the status or the error of the attempt and
+// it ends when the response body is closed.
+func NewTracingMiddleware(tracer Tracer, cfg TracingConfig) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			name := req.Method
+			if route := RouteTemplateFromContext(req.Context()); route != "" {
+				name += " " + route
+			}
+			ctx, span := tracer.Start(req.Context(), name)
+			req = req.WithContext(ctx)
+			span.SetAttributes(
+				Field{Key: "http.request.method", Value: req.Method},
+				Field{Key: "url.full", Value: redactURL(*req.URL)},
+				Field{Key: "server.address", Value: req.URL.Hostname()},
+			)
+			if attempt := AttemptFromContext(ctx); attempt > 1 {
+				span.SetAttributes(Field{Key: "http.request.resend_count", Value: attempt - 1})
+			}
+
+			sc := span.SpanContext()
+			if sc.IsValid() {
+				req.Header.Set("traceparent", sc.Traceparent())
+				if sc.TraceState != "" {
+					req.Header.Set("tracestate", sc.TraceState)
+				} else {
+					req.Header.Del("tracestate")
+				}
+				if cfg.B3 {
+					sampled := "0"
+					if sc.Sampled {
+						sampled = "1"
+					}
+					req.Header.Set("X-B3-TraceId", hex.EncodeToString(sc.TraceID[:]))
+					req.Header.Set("X-B3-SpanId", hex.EncodeToString(sc.SpanID[:]))
+					req.Header.Set("X-B3-Sampled", sampled)
+				}
+			}
+			if cfg.RequestID && req.Header.Get("X-Request-Id") == "" {
+				if scope, ok := ctx.Value(requestScopeKey{}).(*requestScope); ok {
+					req.Header.Set("X-Request-Id", scope.requestID())
+				} else {
+					req.Header.Set("X-Request-Id", newRequestID())
+				}
+			}
+
+			resp, err := next(req)
+			if err != nil {
+				span.RecordError(err)
+				span.End()
+				return resp, err
+			}
+			span.SetAttributes(Field{Key: "http.response.status_code", Value: resp.StatusCode})
+			if resp.StatusCode >= 400 {
+				span.RecordError(fmt.Errorf("unexpected status %d", resp.StatusCode))
+			}
+			resp.Body = &spanBody{ReadCloser: resp.Body, span: span}
+			return resp, nil
+		}
+	}
+}
+
+// spanBody ends the span once on close, a read error other than io.EOF is recorded on the span
+type spanBody struct {
+	io.ReadCloser
+	span Span
+	once sync.Once
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		b.span.RecordError(err)
+	}
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.span.End)
+	return err
+}
+
+type requestScopeKey struct{}
+
+// requestScope holds what is shared by the attempts of a request, it is put in the request context by Client.do
+type requestScope struct {
+	once sync.Once
+	id   string
+	// retrySkipped is why the returned response wasn't retried although the retry policy allows it
+	retrySkipped error
+}
+
+func (s *requestScope) requestID() string {
+	s.once.Do(func() { s.id = newRequestID() })
+	return s.id
+}
+
+// newRequestID returns a random uuid v4
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
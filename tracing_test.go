@@ -0,0 +1,119 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	var hits int32
+	var mu sync.Mutex
+	var received []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Header.Clone())
+		mu.Unlock()
+		if atomic.AddInt32(&hits, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var spans []SpanData
+	tracer := &W3CTracer{OnEnd: func(span SpanData) {
+		mu.Lock()
+		defer mu.Unlock()
+		spans = append(spans, span)
+	}}
+	hc, err := NewHTTPClient(ConfigOptions{
+		ClientOptions: []ClientOption{
+			WithGoStdClient(server.Client()),
+			WithMiddlewares(NewTracingMiddleware(tracer, TracingConfig{B3: true, RequestID: true})),
+		},
+		StaticRequestOptions: []StaticRequestOption{
+			WithURL(server.URL),
+			WithEnvoyRetryPolicy(EnvoyRetryPolicy{
+				MaxRetries:    1,
+				TotalTimeout:  5 * time.Second,
+				PerTryTimeout: time.Second,
+				RetryOn:       []RetryOnCode{RetryOn5xx},
+				BaseInterval:  time.Millisecond,
+			}),
+			WithRetryMode(RetryModeInProcess),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parent, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.TraceState = "vendor=1"
+	ctx := ContextWithSpanContext(context.Background(), parent)
+	if err := hc.Send(ctx, WithRouteTemplate("/items"), WithResponseBody(io.Discard)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(spans) != 2 || len(received) != 2 {
+		t.Fatalf("expected 2 spans and 2 attempts, got %v and %v", len(spans), len(received))
+	}
+	for idx, span := range spans {
+		if span.Name != "GET /items" {
+			t.Errorf("test case %v: expected %v, got %v", idx, "GET /items", span.Name)
+		}
+		if span.Parent != parent || span.SpanContext.TraceID != parent.TraceID {
+			t.Errorf("test case %v: expected the span to be a child of %v, got %v", idx, parent.Traceparent(), span.Parent.Traceparent())
+		}
+		header := received[idx]
+		if v := header.Get("traceparent"); v != span.SpanContext.Traceparent() {
+			t.Errorf("test case %v: expected %v, got %v", idx, span.SpanContext.Traceparent(), v)
+		}
+		if v := header.Get("tracestate"); v != "vendor=1" {
+			t.Errorf("test case %v: expected %v, got %v", idx, "vendor=1", v)
+		}
+		if v := header.Get("X-B3-TraceId"); v != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("test case %v: expected %v, got %v", idx, "4bf92f3577b34da6a3ce929d0e0e4736", v)
+		}
+		if v := header.Get("X-Request-Id"); v == "" || v != received[0].Get("X-Request-Id") {
+			t.Errorf("test case %v: expected the request id to be stable across attempts, got %v", idx, v)
+		}
+	}
+	if spans[0].SpanContext.SpanID == spans[1].SpanContext.SpanID {
+		t.Errorf("expected a span per attempt")
+	}
+	if len(spans[0].Errors) != 1 || len(spans[1].Errors) != 0 {
+		t.Errorf("expected only the failed attempt to record an error, got %v and %v", spans[0].Errors, spans[1].Errors)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	testCases := []struct {
+		traceparent string
+		valid       bool
+	}{
+		{traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true},
+		{traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future", valid: true},
+		{traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{traceparent: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01"},
+	}
+	for idx, testCase := range testCases {
+		sc, err := ParseTraceparent(testCase.traceparent)
+		if (err == nil) != testCase.valid {
+			t.Errorf("test case %v: expected valid to be %v, got %v", idx, testCase.valid, err)
+		}
+		if err == nil && strings.HasPrefix(testCase.traceparent, "00-") && sc.Traceparent() != testCase.traceparent {
+			t.Errorf("test case %v: expected %v, got %v", idx, testCase.traceparent, sc.Traceparent())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// SpanData is a span ended by the W3CTracer
+type SpanData struct {
+	Name        string
+	SpanContext SpanContext
+	// Parent is the zero value for root spans
+	Parent     SpanContext
+	Start      time.Time
+	End        time.Time
+	Attributes []Field
+	Errors     []error
+}
+
+// W3CTracer is a dependency free Tracer that propagates W3C trace context. the ended spans are passed to
+// OnEnd, e.g. to log them. the parent span is taken from ContextWithSpanContext, spans without a parent
+// start a new sampled trace.
+type W3CTracer struct {
+	OnEnd func(SpanData)
+}
+
+func (t *W3CTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{Sampled: true}
+	if parent.IsValid() {
+		sc.TraceID, sc.Sampled, sc.TraceState = parent.TraceID, parent.Sampled, parent.TraceState
+	} else {
+		_, _ = rand.Read(sc.TraceID[:])
+	}
+	_, _ = rand.Read(sc.SpanID[:])
+	span := &w3cSpan{
+		tracer: t,
+		data:   SpanData{Name: name, SpanContext: sc, Parent: parent, Start: time.Now()},
+	}
+	return ContextWithSpanContext(ctx, sc), span
+}
+
+type w3cSpan struct {
+	tracer *W3CTracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+func (s *w3cSpan) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *w3cSpan) SetAttributes(fields ...Field) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, fields...)
+}
+
+func (s *w3cSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Errors = append(s.data.Errors, err)
+}
+
+func (s *w3cSpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	if s.tracer.OnEnd != nil {
+		s.tracer.OnEnd(data)
+	}
+}
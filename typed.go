@@ -0,0 +1,41 @@
+package httpclient
+
+import "context"
+
+// Do sends the request and decodes the json response into a new value of type Resp.
+// e.g. user, err := httpclient.Do[User](ctx, c, WithPath("/users/1"))
+func Do[Resp any](ctx context.Context, c *Client, opts ...RuntimeRequestOption) (Resp, error) {
+	var resp Resp
+	// response destination is appended last so that it always wins over the given options
+	opts = append(opts[:len(opts):len(opts)], WithResponseJSON(&resp))
+	if err := c.Send(ctx, opts...); err != nil {
+		var zero Resp
+		return zero, err
+	}
+	return resp, nil
+}
+
+func Get[Resp any](ctx context.Context, c *Client, opts ...RuntimeRequestOption) (Resp, error) {
+	return Do[Resp](ctx, c, prependOptions(opts, WithMethod(MethodGet))...)
+}
+
+func Delete[Resp any](ctx context.Context, c *Client, opts ...RuntimeRequestOption) (Resp, error) {
+	return Do[Resp](ctx, c, prependOptions(opts, WithMethod(MethodDelete))...)
+}
+
+func PostJSON[Req, Resp any](ctx context.Context, c *Client, body Req, opts ...RuntimeRequestOption) (Resp, error) {
+	return Do[Resp](ctx, c, prependOptions(opts, WithMethod(MethodPost), WithRequestJSON(body))...)
+}
+
+func PutJSON[Req, Resp any](ctx context.Context, c *Client, body Req, opts ...RuntimeRequestOption) (Resp, error) {
+	return Do[Resp](ctx, c, prependOptions(opts, WithMethod(MethodPut), WithRequestJSON(body))...)
+}
+
+func PatchJSON[Req, Resp any](ctx context.Context, c *Client, body Req, opts ...RuntimeRequestOption) (Resp, error) {
+	return Do[Resp](ctx, c, prependOptions(opts, WithMethod(MethodPatch), WithRequestJSON(body))...)
+}
+
+// prependOptions puts the defaults before the given options so that callers can still override them
+func prependOptions(opts []RuntimeRequestOption, defaults ...RuntimeRequestOption) []RuntimeRequestOption {
+	return append(defaults, opts...)
+}
@@ -0,0 +1,51 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTypedHelpers(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`{"id":1,"name":"alice"}`))
+		case http.MethodPost:
+			var u user
+			if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			u.ID = 2
+			_ = json.NewEncoder(w).Encode(u)
+		default:
+			t.Errorf("unexpected method %v", r.Method)
+		}
+	}))
+	defer server.Close()
+
+	hc := newTestClient(t, server)
+
+	u, err := Get[user](context.Background(), hc, WithPath("/users/1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.ID != 1 || u.Name != "alice" {
+		t.Errorf("expected %v, got %v", user{ID: 1, Name: "alice"}, u)
+	}
+
+	created, err := PostJSON[user, user](context.Background(), hc, user{Name: "bob"}, WithPath("/users"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created.ID != 2 || created.Name != "bob" {
+		t.Errorf("expected %v, got %v", user{ID: 2, Name: "bob"}, created)
+	}
+}
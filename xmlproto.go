@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// XMLCodec is the encoding/xml codec, it is used for xml unless another one is registered for application/xml.
+var XMLCodec Codec = xmlCodec{}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml"
+}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+func (xmlCodec) NewDecoder(r io.Reader) Decoder {
+	return xml.NewDecoder(r)
+}
+
+// ProtoMarshaler is implemented by protobuf messages, e.g. the ones generated by gogo/protobuf. messages of
+// other generators can be adapted with a small wrapper, so that no protobuf library is forced on the users.
+type ProtoMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// ProtoUnmarshaler is the decoding counterpart of ProtoMarshaler
+type ProtoUnmarshaler interface {
+	Unmarshal(data []byte) error
+}
+
+// ProtoCodec encodes ProtoMarshaler and decodes into ProtoUnmarshaler values as application/x-protobuf.
+var ProtoCodec Codec = protoCodec{}
+
+type protoCodec struct{}
+
+func (protoCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(ProtoMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement ProtoMarshaler", v)
+	}
+	return m.Marshal()
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(ProtoUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%T does not implement ProtoUnmarshaler", v)
+	}
+	return m.Unmarshal(data)
+}
+
+func (c protoCodec) NewDecoder(r io.Reader) Decoder {
+	return protoDecoder{r: r}
+}
+
+// protoDecoder buffers the message, protobuf can't be decoded without knowing where the message ends
+type protoDecoder struct {
+	r io.Reader
+}
+
+func (d protoDecoder) Decode(v interface{}) error {
+	data, err := io.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return io.EOF
+	}
+	return protoCodec{}.Unmarshal(data, v)
+}
+
+// WithRequestXML sets the body to body encoded with the xml codec of the client when the request is sent.
+func WithRequestXML(body interface{}) RuntimeRequestOption {
+	return withRequestBody(body, "application/xml")
+}
+
+// WithResponseXML decodes the response body into response with the xml codec of the client.
+func WithResponseXML(response interface{}) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		c.ResponseXML = response
+		return c, nil
+	}
+}
+
+// WithRequestProto sets the body to the encoded message.
+func WithRequestProto(message ProtoMarshaler) RuntimeRequestOption {
+	if message == nil {
+		return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+			return c, errors.New("proto message is nil")
+		}
+	}
+	return withRequestBody(message, "application/x-protobuf")
+}
+
+// WithResponseProto decodes the response body into message.
+func WithResponseProto(message ProtoUnmarshaler) RuntimeRequestOption {
+	return func(c RuntimeRequestConfig) (RuntimeRequestConfig, error) {
+		c.ResponseProto = message
+		return c, nil
+	}
+}
@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeMessage mimics a generated protobuf message, it is encoded as its name prefixed with its length
+type fakeMessage struct {
+	Name string
+}
+
+func (m *fakeMessage) Marshal() ([]byte, error) {
+	return append([]byte{byte(len(m.Name))}, m.Name...), nil
+}
+
+func (m *fakeMessage) Unmarshal(data []byte) error {
+	if len(data) == 0 || int(data[0]) != len(data)-1 {
+		return errors.New("invalid message")
+	}
+	m.Name = string(data[1:])
+	return nil
+}
+
+func TestXMLAndProto(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		if r.URL.Path == "/text-xml" {
+			w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		}
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+	hc := newTestClient(t, server)
+
+	type item struct {
+		XMLName xml.Name `xml:"item"`
+		Name    string   `xml:"name"`
+	}
+	var xmlResp item
+	var raw *http.Response
+	capture := WithRequestMiddlewares(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			var err error
+			raw, err = next(req)
+			return raw, err
+		}
+	})
+	err := hc.Send(context.Background(), WithMethod(MethodPost), WithRequestXML(item{Name: "x"}), WithResponseXML(&xmlResp), capture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if xmlResp.Name != "x" {
+		t.Errorf("expected %v, got %v", "x", xmlResp.Name)
+	}
+	if accept := raw.Header.Get("X-Accept"); accept != "application/xml" {
+		t.Errorf("expected %v, got %v", "application/xml", accept)
+	}
+
+	// text/xml is decoded by the xml codec when the response is negotiated
+	var negotiated item
+	err = hc.Send(context.Background(), WithMethod(MethodPost), WithPath("/text-xml"), WithRequestXML(item{Name: "y"}), WithResponse(&negotiated))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if negotiated.Name != "y" {
+		t.Errorf("expected %v, got %v", "y", negotiated.Name)
+	}
+
+	var protoResp fakeMessage
+	err = hc.Send(context.Background(), WithMethod(MethodPost), WithRequestProto(&fakeMessage{Name: "z"}), WithResponseProto(&protoResp), capture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if protoResp.Name != "z" {
+		t.Errorf("expected %v, got %v", "z", protoResp.Name)
+	}
+	if accept := raw.Header.Get("X-Accept"); accept != "application/x-protobuf" {
+		t.Errorf("expected %v, got %v", "application/x-protobuf", accept)
+	}
+
+	err = hc.Send(context.Background(), WithRequestProto(nil), WithResponseProto(&protoResp))
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || configErr.Index != 0 {
+		t.Errorf("expected a config error at index 0, got %v", err)
+	}
+	err = hc.Send(context.Background(), WithResponseXML(&xmlResp), WithResponseProto(&protoResp))
+	if !errors.As(err, &configErr) || !strings.Contains(err.Error(), "only one of") {
+		t.Errorf("expected a config error, got %v", err)
+	}
+}